@@ -1,11 +1,14 @@
 package learning
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/billykore/go/learning/middleware"
 )
 
 func SetCookie(writer http.ResponseWriter, request *http.Request) {
@@ -71,3 +74,59 @@ func TestGetCookie(t *testing.T) {
 	body, _ := io.ReadAll(response.Body)
 	fmt.Println(string(body))
 }
+
+// sessions signs the session cookies, unlike the X-BK-Name cookie that
+// anyone can forge. A real key comes from configuration.
+var sessions, _ = middleware.NewSessions(middleware.CookieOptions{
+	HashKey: bytes.Repeat([]byte("k"), 32),
+})
+
+func Login(writer http.ResponseWriter, request *http.Request) {
+	if err := sessions.Login(writer, request.URL.Query().Get("name")); err != nil {
+		panic(err)
+	}
+	fmt.Fprint(writer, "Success login")
+}
+
+func Hello(writer http.ResponseWriter, request *http.Request) {
+	principal, _ := middleware.PrincipalFromContext(request.Context())
+	fmt.Fprintf(writer, "Hello %s", principal.Subject)
+}
+
+// authenticate lets through requests with a valid session cookie or bearer
+// token and answers the rest like any unauthorized exception.
+var authenticate = middleware.Authenticate(middleware.AuthOptions{
+	Sessions:    sessions,
+	VerifyToken: sessions.VerifyToken,
+	OnError: func(writer http.ResponseWriter, request *http.Request, err error) {
+		res := sendErrorResponse(&exception{exceptionKindUnauthorized, unauthorizedException})
+		middleware.WriteError(writer, res.code, res.message)
+	},
+})
+
+func TestSessionCookie(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", Login)
+	mux.Handle("/hello", authenticate(http.HandlerFunc(Hello)))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/login?name=Billy", nil))
+	cookie := recorder.Result().Cookies()[0]
+
+	request := httptest.NewRequest("GET", "/hello", nil)
+	request.AddCookie(cookie)
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	body, _ := io.ReadAll(recorder.Result().Body)
+	if string(body) != "Hello Billy" {
+		t.Errorf("expected Hello Billy, got %q", body)
+	}
+
+	request = httptest.NewRequest("GET", "/hello", nil)
+	request.AddCookie(&http.Cookie{Name: cookie.Name, Value: "Florence Fedora"})
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected a forged cookie to be unauthorized, got %d", recorder.Code)
+	}
+}
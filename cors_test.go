@@ -0,0 +1,46 @@
+package learning
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/billykore/go/learning/middleware"
+)
+
+var cors = middleware.CORS(middleware.CORSOptions{
+	AllowedOrigins:   []string{"http://localhost:3000", "https://*.billykore.dev"},
+	AllowedMethods:   []string{"GET", "POST"},
+	AllowedHeaders:   []string{"Content-Type"},
+	AllowCredentials: true,
+	MaxAge:           time.Hour,
+})
+
+func TestCORS(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", SayHello)
+	mux.HandleFunc("/form", FormPost)
+	mux.HandleFunc("/header", RequestHeader)
+	handler := cors(mux)
+
+	request := httptest.NewRequest("OPTIONS", "/form", nil)
+	request.Header.Set("Origin", "https://app.billykore.dev")
+	request.Header.Set("Access-Control-Request-Method", "POST")
+	request.Header.Set("Access-Control-Request-Headers", "content-type")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	fmt.Println(recorder.Code)
+	fmt.Println(recorder.Header().Get("Access-Control-Allow-Origin"))
+	fmt.Println(recorder.Header().Get("Access-Control-Allow-Methods"))
+
+	request = httptest.NewRequest("GET", "/hello?name=Billy", nil)
+	request.Header.Set("Origin", "http://localhost:3000")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	fmt.Println(recorder.Header().Get("Access-Control-Allow-Origin"))
+	fmt.Println(recorder.Body.String())
+}
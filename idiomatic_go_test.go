@@ -28,6 +28,8 @@ import (
 	"testing"
 	"time"
 	"unsafe"
+
+	"github.com/billykore/go/learning/middleware"
 )
 
 func countTo(max int) (<-chan int, func()) {
@@ -483,7 +485,9 @@ func fastServer() *httptest.Server {
 	return s
 }
 
-var client = &http.Client{}
+// client passes the request ID of a request's context on to the server
+// it calls.
+var client = &http.Client{Transport: middleware.Transport(nil)}
 
 func callBoth(ctx context.Context, errVal string, slowURL string, fastURL string) {
 	ctx, cancel := context.WithCancel(ctx)
@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is wrapped by every error authentication fails with.
+var ErrUnauthorized = errors.New("unauthorized")
+
+func unauthorized(reason string) error {
+	return fmt.Errorf("%w: %s", ErrUnauthorized, reason)
+}
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	Subject string    `json:"sub"`
+	Expires time.Time `json:"exp"`
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal Authenticate put in ctx.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+// CookieOptions configures Sessions.
+type CookieOptions struct {
+	// Name of the cookie, "session" if empty.
+	Name string
+	// HashKey signs the cookies with HMAC-SHA256. It must be at least 32
+	// bytes of random data.
+	HashKey []byte
+	// BlockKey encrypts the cookies with AES-GCM if set. It must be 16, 24
+	// or 32 bytes.
+	BlockKey []byte
+	// TTL is how long a session lasts, 24 hours if zero.
+	TTL time.Duration
+	// Insecure allows the cookie over plain HTTP, for local development.
+	Insecure bool
+}
+
+// Sessions issues and verifies signed session cookies and bearer tokens.
+// Both carry a Principal and its expiry, so no state is kept on the
+// server.
+type Sessions struct {
+	name     string
+	hashKey  []byte
+	aead     cipher.AEAD
+	ttl      time.Duration
+	insecure bool
+	now      func() time.Time
+}
+
+// NewSessions returns Sessions using the keys in opts.
+func NewSessions(opts CookieOptions) (*Sessions, error) {
+	if len(opts.HashKey) < 32 {
+		return nil, errors.New("middleware: HashKey must be at least 32 bytes")
+	}
+	s := &Sessions{
+		name:     opts.Name,
+		hashKey:  opts.HashKey,
+		ttl:      opts.TTL,
+		insecure: opts.Insecure,
+		now:      time.Now,
+	}
+	if s.name == "" {
+		s.name = "session"
+	}
+	if s.ttl == 0 {
+		s.ttl = 24 * time.Hour
+	}
+	if opts.BlockKey != nil {
+		block, err := aes.NewCipher(opts.BlockKey)
+		if err != nil {
+			return nil, fmt.Errorf("middleware: invalid BlockKey: %w", err)
+		}
+		if s.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Login sets a session cookie for subject.
+func (s *Sessions) Login(w http.ResponseWriter, subject string) error {
+	p := Principal{Subject: subject, Expires: s.now().Add(s.ttl).Truncate(time.Second)}
+	value, err := s.encode(s.name, p)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    value,
+		Path:     "/",
+		Expires:  p.Expires,
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   !s.insecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout removes the session cookie.
+func (s *Sessions) Logout(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   !s.insecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// NewToken returns a bearer token for subject.
+func (s *Sessions) NewToken(subject string) (string, error) {
+	return s.encode("token", Principal{Subject: subject, Expires: s.now().Add(s.ttl).Truncate(time.Second)})
+}
+
+// VerifyToken checks a token made by NewToken. It fits
+// AuthOptions.VerifyToken.
+func (s *Sessions) VerifyToken(ctx context.Context, token string) (Principal, error) {
+	return s.decode("token", token)
+}
+
+func (s *Sessions) verifyCookie(r *http.Request) (Principal, error) {
+	c, err := r.Cookie(s.name)
+	if err != nil {
+		return Principal{}, unauthorized("no session cookie")
+	}
+	return s.decode(s.name, c.Value)
+}
+
+// encode serializes p, encrypts it if there is a block key and signs it.
+// The purpose is signed along, so a cookie can't be used as a token or
+// under another cookie name.
+func (s *Sessions) encode(purpose string, p Principal) (string, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = s.aead.Seal(nonce, nonce, payload, []byte(purpose))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(purpose, encoded)), nil
+}
+
+func (s *Sessions) decode(purpose, value string) (Principal, error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return Principal{}, unauthorized("malformed credentials")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.sign(purpose, encoded)) {
+		return Principal{}, unauthorized("invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Principal{}, unauthorized("malformed credentials")
+	}
+	if s.aead != nil {
+		n := s.aead.NonceSize()
+		if len(payload) < n {
+			return Principal{}, unauthorized("malformed credentials")
+		}
+		if payload, err = s.aead.Open(nil, payload[:n], payload[n:], []byte(purpose)); err != nil {
+			return Principal{}, unauthorized("undecryptable credentials")
+		}
+	}
+	var p Principal
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return Principal{}, unauthorized("malformed credentials")
+	}
+	if !s.now().Before(p.Expires) {
+		return Principal{}, unauthorized("credentials expired")
+	}
+	return p, nil
+}
+
+func (s *Sessions) sign(purpose, encoded string) []byte {
+	h := hmac.New(sha256.New, s.hashKey)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// AuthOptions configures Authenticate. At least one way of
+// authenticating must be set.
+type AuthOptions struct {
+	// Sessions accepts its session cookies.
+	Sessions *Sessions
+	// VerifyToken accepts the bearer tokens it returns a principal for.
+	// Its errors should wrap ErrUnauthorized.
+	VerifyToken func(ctx context.Context, token string) (Principal, error)
+	// OnError writes the response to a request that failed to
+	// authenticate. The default is a 401 ErrorResponse.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Authenticate lets through requests with a valid bearer token in their
+// Authorization header or a valid session cookie, with the Principal in
+// their context. A request with a bearer token is judged by the token
+// alone.
+func Authenticate(opts AuthOptions) Middleware {
+	if opts.Sessions == nil && opts.VerifyToken == nil {
+		panic("middleware: Authenticate needs Sessions or VerifyToken")
+	}
+	if opts.OnError == nil {
+		opts.OnError = writeUnauthorized
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticate(opts, r)
+			if err != nil {
+				opts.OnError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func authenticate(opts AuthOptions, r *http.Request) (Principal, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, _ := strings.Cut(auth, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" || opts.VerifyToken == nil {
+			return Principal{}, unauthorized("unsupported authorization")
+		}
+		return opts.VerifyToken(r.Context(), strings.TrimSpace(token))
+	}
+	if opts.Sessions == nil {
+		return Principal{}, unauthorized("no bearer token")
+	}
+	return opts.Sessions.verifyCookie(r)
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	WriteError(w, http.StatusUnauthorized, "unauthorized")
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHashKey = bytes.Repeat([]byte("h"), 32)
+
+func newTestSessions(t *testing.T, opts CookieOptions) (*Sessions, *fakeClock) {
+	t.Helper()
+	s, err := NewSessions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)}
+	s.now = clock.Now
+	return s, clock
+}
+
+func whoami(w http.ResponseWriter, r *http.Request) {
+	p, _ := PrincipalFromContext(r.Context())
+	w.Write([]byte(p.Subject))
+}
+
+func TestAuthenticate_Cookie(t *testing.T) {
+	for _, blockKey := range [][]byte{nil, bytes.Repeat([]byte("b"), 32)} {
+		s, clock := newTestSessions(t, CookieOptions{HashKey: testHashKey, BlockKey: blockKey, TTL: time.Hour})
+		rec := httptest.NewRecorder()
+		s.Login(rec, "billy")
+		cookie := rec.Result().Cookies()[0]
+		if cookie.Name != "session" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge != 3600 {
+			t.Errorf("unexpected cookie %+v", cookie)
+		}
+		if encrypted := blockKey != nil; encrypted == strings.Contains(cookie.Value, "eyJzdWIiOiJiaWxseSI") {
+			t.Errorf("expected the cookie to be encrypted: %v, got %q", encrypted, cookie.Value)
+		}
+
+		h := Authenticate(AuthOptions{Sessions: s})(http.HandlerFunc(whoami))
+		get := func(c *http.Cookie) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/", nil)
+			if c != nil {
+				req.AddCookie(c)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		if rec := get(cookie); rec.Code != http.StatusOK || rec.Body.String() != "billy" {
+			t.Errorf("expected billy to be let in, got %d %q", rec.Code, rec.Body.String())
+		}
+		tampered := *cookie
+		tampered.Value = "x" + cookie.Value[1:]
+		for _, c := range []*http.Cookie{nil, &tampered, {Name: "session", Value: "nodot"}} {
+			if rec := get(c); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("expected cookie %v to be refused, got %d", c, rec.Code)
+			}
+		}
+		clock.now = clock.now.Add(time.Hour)
+		if rec := get(cookie); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected an expired cookie to be refused, got %d", rec.Code)
+		}
+	}
+}
+
+func TestAuthenticate_Bearer(t *testing.T) {
+	s, _ := newTestSessions(t, CookieOptions{HashKey: testHashKey})
+	token, err := s.NewToken("billy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.Login(rec, "billy")
+	cookie := rec.Result().Cookies()[0]
+
+	h := Authenticate(AuthOptions{Sessions: s, VerifyToken: s.VerifyToken})(http.HandlerFunc(whoami))
+	tests := []struct {
+		auth string
+		code int
+	}{
+		{"Bearer " + token, http.StatusOK},
+		{"bearer " + token, http.StatusOK},
+		{"Bearer " + cookie.Value, http.StatusUnauthorized},
+		{"Basic YmlsbHk6cGFzcw==", http.StatusUnauthorized},
+		{"Bearer", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", tt.auth)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("Authorization %q: expected %d, got %d", tt.auth, tt.code, rec.Code)
+		}
+	}
+
+	// a session cookie can't be replayed under another cookie name
+	other, _ := newTestSessions(t, CookieOptions{Name: "other", HashKey: testHashKey})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "other", Value: cookie.Value})
+	if _, err := other.verifyCookie(req); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected a renamed cookie to be refused, got %v", err)
+	}
+}
+
+func TestAuthenticate_OnError(t *testing.T) {
+	var got error
+	h := Authenticate(AuthOptions{
+		VerifyToken: func(ctx context.Context, token string) (Principal, error) {
+			return Principal{}, unauthorized("unknown token")
+		},
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			got = err
+			w.WriteHeader(http.StatusForbidden)
+		},
+	})(http.HandlerFunc(whoami))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || !errors.Is(got, ErrUnauthorized) {
+		t.Errorf("expected OnError to get the error, got %d %v", rec.Code, got)
+	}
+}
+
+func TestNewSessions_InvalidKeys(t *testing.T) {
+	if _, err := NewSessions(CookieOptions{HashKey: []byte("short")}); err == nil {
+		t.Error("expected a short hash key to be refused")
+	}
+	if _, err := NewSessions(CookieOptions{HashKey: testHashKey, BlockKey: []byte("short")}); err == nil {
+		t.Error("expected an invalid block key to be refused")
+	}
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures CORS.
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to call the handler, such as
+	// "https://example.com". An origin may hold one wildcard, as in
+	// "https://*.example.com", and "*" allows every origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed, GET, HEAD and POST if empty.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed, "*" allows any.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read besides
+	// the safelisted ones.
+	ExposedHeaders []string
+	// AllowCredentials lets requests carry cookies and Authorization
+	// headers. The origin is then echoed even if "*" is allowed, browsers
+	// refuse credentials with a wildcard.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight, not at all if 0.
+	MaxAge time.Duration
+}
+
+// CORS lets browser apps on the allowed origins call the handler. It
+// answers preflight requests itself with 204 No Content and adds the
+// Access-Control-Allow-* headers to the responses of allowed origins.
+// Requests of other origins are passed on untouched, the browser keeps
+// their responses from the app.
+func CORS(opts CORSOptions) Middleware {
+	c := newCORS(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type cors struct {
+	anyOrigin   bool
+	origins     []string
+	wildcards   []originPattern
+	methods     []string
+	anyHeader   bool
+	headers     []string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// originPattern is an allowed origin with a wildcard, the origin must
+// start with prefix and end with suffix.
+type originPattern struct {
+	prefix, suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	return len(origin) > len(p.prefix)+len(p.suffix) &&
+		strings.HasPrefix(origin, p.prefix) && strings.HasSuffix(origin, p.suffix)
+}
+
+func newCORS(opts CORSOptions) *cors {
+	c := &cors{
+		methods:     opts.AllowedMethods,
+		exposed:     strings.Join(opts.ExposedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+	}
+	for _, o := range opts.AllowedOrigins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			c.anyOrigin = true
+		} else if prefix, suffix, ok := strings.Cut(o, "*"); ok {
+			c.wildcards = append(c.wildcards, originPattern{prefix, suffix})
+		} else {
+			c.origins = append(c.origins, o)
+		}
+	}
+	if len(c.methods) == 0 {
+		c.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers = append(c.headers, http.CanonicalHeaderKey(h))
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return c
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	return slices.Contains(c.origins, origin) ||
+		slices.ContainsFunc(c.wildcards, func(p originPattern) bool { return p.match(origin) })
+}
+
+func (c *cors) allowHeaders(requested string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !slices.Contains(c.headers, http.CanonicalHeaderKey(h)) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin sets Access-Control-Allow-Origin and, with credentials,
+// Access-Control-Allow-Credentials.
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if c.allowOrigin(origin) && slices.Contains(c.methods, method) && c.allowHeaders(requested) {
+		c.setOrigin(h, origin)
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+		if requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	// The response depends on the origin unless every origin gets the
+	// same "*", caches must not hand it to another origin.
+	if !c.anyOrigin || c.credentials {
+		h.Add("Vary", "Origin")
+	}
+	origin := r.Header.Get("Origin")
+	if !c.allowOrigin(origin) {
+		return
+	}
+	c.setOrigin(h, origin)
+	if c.exposed != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposed)
+	}
+}
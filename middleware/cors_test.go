@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func serveCORS(opts CORSOptions, method, origin string, header http.Header) *httptest.ResponseRecorder {
+	h := CORS(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "3")
+		w.Write([]byte("ok"))
+	}))
+	req := httptest.NewRequest(method, "/todos", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORS(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"X-Total-Count"},
+		MaxAge:         10 * time.Minute,
+	}
+	tests := []struct {
+		name   string
+		origin string
+		want   string
+	}{
+		{"exact", "https://app.example.com", "https://app.example.com"},
+		{"exact ignoring case", "https://App.Example.com", "https://App.Example.com"},
+		{"wildcard", "https://eu.example.org", "https://eu.example.org"},
+		{"wildcard needs a subdomain", "https://.example.org", ""},
+		{"wildcard keeps the scheme", "http://eu.example.org", ""},
+		{"other origin", "https://evil.com", ""},
+		{"same origin", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCORS(opts, "GET", tt.origin, nil)
+			h := rec.Header()
+			if rec.Body.String() != "ok" {
+				t.Errorf("expected the handler to run, got %q", rec.Body.String())
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.want, got)
+			}
+			if !slices.Contains(h.Values("Vary"), "Origin") {
+				t.Errorf("expected Vary: Origin, got %q", h.Values("Vary"))
+			}
+			wantExposed := ""
+			if tt.want != "" {
+				wantExposed = "X-Total-Count"
+			}
+			if got := h.Get("Access-Control-Expose-Headers"); got != wantExposed {
+				t.Errorf("expected Access-Control-Expose-Headers %q, got %q", wantExposed, got)
+			}
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	}
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"allowed", "https://app.example.com", "DELETE", "content-type, authorization", true},
+		{"no headers", "https://app.example.com", "POST", "", true},
+		{"method not allowed", "https://app.example.com", "PUT", "", false},
+		{"header not allowed", "https://app.example.com", "POST", "content-type, x-debug", false},
+		{"origin not allowed", "https://evil.com", "GET", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Access-Control-Request-Method": {tt.method}}
+			if tt.headers != "" {
+				header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := serveCORS(opts, "OPTIONS", tt.origin, header)
+			h := rec.Header()
+			if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+				t.Errorf("expected an empty 204, got %d %q", rec.Code, rec.Body.String())
+			}
+			wantVary := []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+			if !slices.Equal(h.Values("Vary"), wantVary) {
+				t.Errorf("expected Vary %q, got %q", wantVary, h.Values("Vary"))
+			}
+			if got := h.Get("Access-Control-Allow-Origin") != ""; got != tt.allowed {
+				t.Fatalf("expected allowed %v, got headers %v", tt.allowed, h)
+			}
+			if !tt.allowed {
+				return
+			}
+			if got := h.Get("Access-Control-Allow-Methods"); got != "GET, POST, DELETE" {
+				t.Errorf("unexpected Access-Control-Allow-Methods %q", got)
+			}
+			if got := h.Get("Access-Control-Allow-Headers"); got != tt.headers {
+				t.Errorf("expected Access-Control-Allow-Headers %q, got %q", tt.headers, got)
+			}
+			if got := h.Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("expected Access-Control-Max-Age 600, got %q", got)
+			}
+		})
+	}
+
+	// a plain OPTIONS request is no preflight, the handler answers it
+	if rec := serveCORS(opts, "OPTIONS", "https://app.example.com", nil); rec.Body.String() != "ok" {
+		t.Errorf("expected the handler to answer OPTIONS, got %q", rec.Body.String())
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	rec := serveCORS(CORSOptions{AllowedOrigins: []string{"*"}}, "GET", "https://app.example.com", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 0 {
+		t.Errorf("expected no Vary for the same answer to every origin, got %q", vary)
+	}
+
+	// browsers refuse credentials with *, so the origin is echoed
+	opts := CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}, AllowCredentials: true}
+	header := http.Header{
+		"Access-Control-Request-Method":  {"POST"},
+		"Access-Control-Request-Headers": {"x-anything"},
+	}
+	for _, method := range []string{"GET", "OPTIONS"} {
+		rec := serveCORS(opts, method, "https://app.example.com", header)
+		h := rec.Header()
+		if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s: expected the origin with credentials, got %v", method, h)
+		}
+		if !slices.Contains(h.Values("Vary"), "Origin") {
+			t.Errorf("%s: expected Vary: Origin, got %q", method, h.Values("Vary"))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Log(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "Hello")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	req := httptest.NewRequest("POST", "/todos?x=1", nil)
+	req = req.WithContext(WithRequestID(req.Context(), "abc"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level":       "INFO",
+		"msg":         "request",
+		"method":      "POST",
+		"path":        "/todos",
+		"status":      float64(http.StatusCreated),
+		"bytes":       float64(5),
+		"remote_addr": "192.0.2.1:1234",
+		"request_id":  "abc",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("expected %s to be %v, got %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["duration"].(float64); !ok {
+		t.Errorf("expected a duration, got %v", record["duration"])
+	}
+}
+
+func TestLog_DefaultStatus(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := NewLogger(&buf, "text")
+	Log(logger)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	Log(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	Log(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got %q", buf.String())
+	}
+	for i, want := range []string{"level=INFO msg=request method=GET path=/missing status=404 bytes=19", "status=200 bytes=0", "level=ERROR"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("expected record %d to contain %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestNewLogger_UnknownFormat(t *testing.T) {
+	if _, err := NewLogger(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
+
+func TestResponseWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Errorf("expected flushing through the wrapper to work, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("expected the wrapped writer to be flushed")
+	}
+}
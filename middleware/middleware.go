@@ -0,0 +1,46 @@
+// Package middleware holds HTTP middlewares that can be composed into a
+// chain in the order they are declared.
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// contextKey is the type of the request context keys of this package.
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	principalKey
+	sessionKey
+)
+
+// Middleware wraps a handler with behaviour that runs around it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one. The first middleware is the
+// outermost, so Chain(a, b)(h) runs a, then b, then h.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// ErrorResponse is the JSON body of error responses, the same envelope
+// the APIs use for their own errors.
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteError writes an ErrorResponse with the given status code.
+func WriteError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ErrorResponse{Code: code, Message: message})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func tag(name string, calls *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name+" before")
+			next.ServeHTTP(w, r)
+			*calls = append(*calls, name+" after")
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	h := Chain(tag("a", &calls), tag("b", &calls))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := "a before,b before,handler,b after,a after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestChain_Empty(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello")
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "Hello" {
+		t.Errorf("expected the handler to run, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the key of the client a request is rate limited as.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP limits each client IP address on its own.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByPrincipal limits each authenticated principal on its own, and
+// requests without one by IP address. It must run after Authenticate, so
+// only credentials that were checked get a bucket of their own.
+func KeyByPrincipal(r *http.Request) string {
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		return "principal:" + p.Subject
+	}
+	return KeyByIP(r)
+}
+
+// KeyByHeader limits each value of the header, such as an API key, on
+// its own if known reports it as valid. Requests without the header or
+// with an unknown value are limited by IP address, so clients can't get a
+// fresh bucket by sending a made-up key with every request.
+func KeyByHeader(name string, known func(value string) bool) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" && known(v) {
+			return "header:" + v
+		}
+		return KeyByIP(r)
+	}
+}
+
+// RateLimitOptions configures RateLimit. Each client may make Limit
+// requests at once, and gets them back at an even rate over Per.
+type RateLimitOptions struct {
+	Limit int
+	Per   time.Duration
+	// Key tells clients apart, KeyByIP if nil.
+	Key KeyFunc
+}
+
+// RateLimit rejects requests of clients that used up their requests with
+// 429 Too Many Requests and a Retry-After header. Every response tells
+// the client where it stands with the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers.
+//
+// It panics if Limit or Per is not positive.
+func RateLimit(opts RateLimitOptions) Middleware {
+	return rateLimit(newLimiter(opts))
+}
+
+func rateLimit(l *limiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := l.take(l.key(r))
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(l.limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.remaining))
+			h.Set("RateLimit-Reset", seconds(res.reset))
+			if !res.ok {
+				h.Set("Retry-After", seconds(res.retryAfter))
+				WriteError(w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats d as whole seconds, rounded up so clients don't come
+// back too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// limiter hands out requests from a token bucket per client, like a
+// pressure gauge that refills over time. A client idle long enough for
+// its bucket to fill up is the same as a new one, so such clients are
+// dropped to keep memory bounded.
+type limiter struct {
+	mu        sync.Mutex
+	limit     int
+	per       time.Duration
+	key       KeyFunc
+	clients   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type takeResult struct {
+	ok         bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func newLimiter(opts RateLimitOptions) *limiter {
+	if opts.Limit <= 0 || opts.Per <= 0 {
+		panic("middleware: RateLimit needs a positive Limit and Per")
+	}
+	if opts.Key == nil {
+		opts.Key = KeyByIP
+	}
+	return &limiter{
+		limit:   opts.Limit,
+		per:     opts.Per,
+		key:     opts.Key,
+		clients: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// take takes a token from the client's bucket if there is one left.
+func (l *limiter) take(key string) takeResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.clients[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit), last: now}
+		l.clients[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	var res takeResult
+	if b.tokens >= 1 {
+		b.tokens--
+		res.ok = true
+	} else {
+		res.retryAfter = l.timeFor(1 - b.tokens)
+	}
+	res.remaining = int(b.tokens)
+	res.reset = l.timeFor(float64(l.limit) - b.tokens)
+	return res
+}
+
+// refill returns the tokens in b at now.
+func (l *limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(l.limit)*float64(now.Sub(b.last))/float64(l.per)
+	return min(tokens, float64(l.limit))
+}
+
+// timeFor returns how long it takes to refill n tokens.
+func (l *limiter) timeFor(n float64) time.Duration {
+	return time.Duration(n * float64(l.per) / float64(l.limit))
+}
+
+// sweep drops the clients whose buckets are full again, at most once per
+// refill period.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.clients {
+		if l.refill(b, now) >= float64(l.limit) {
+			delete(l.clients, key)
+		}
+	}
+}
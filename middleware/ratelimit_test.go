@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(opts RateLimitOptions) (*limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)}
+	l := newLimiter(opts)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestRateLimit(t *testing.T) {
+	l, clock := newTestLimiter(RateLimitOptions{Limit: 2, Per: 10 * time.Second})
+	h := rateLimit(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	steps := []struct {
+		after      time.Duration
+		addr       string
+		code       int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{0, "192.0.2.1:1000", 200, "1", "5", ""},
+		{0, "192.0.2.1:2000", 200, "0", "10", ""},
+		{0, "192.0.2.1:1000", 429, "0", "10", "5"},
+		{0, "192.0.2.2:1000", 200, "1", "5", ""},
+		{2 * time.Second, "192.0.2.1:1000", 429, "0", "8", "3"},
+		{3 * time.Second, "192.0.2.1:1000", 200, "0", "10", ""},
+	}
+	for i, step := range steps {
+		clock.now = clock.now.Add(step.after)
+		rec := get(step.addr)
+		h := rec.Header()
+		if rec.Code != step.code || h.Get("RateLimit-Remaining") != step.remaining || h.Get("RateLimit-Reset") != step.reset || h.Get("Retry-After") != step.retryAfter {
+			t.Errorf("step %d: expected %d remaining=%s reset=%s retry=%q, got %d remaining=%s reset=%s retry=%q", i,
+				step.code, step.remaining, step.reset, step.retryAfter,
+				rec.Code, h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"))
+		}
+		if h.Get("RateLimit-Limit") != "2" {
+			t.Errorf("step %d: expected RateLimit-Limit 2, got %q", i, h.Get("RateLimit-Limit"))
+		}
+	}
+	if rec := get("192.0.2.1:1000"); rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON error, got %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestRateLimit_Eviction(t *testing.T) {
+	l, clock := newTestLimiter(RateLimitOptions{Limit: 5, Per: time.Minute})
+	l.take("a")
+	clock.now = clock.now.Add(30 * time.Second)
+	for range 5 {
+		l.take("b")
+	}
+	clock.now = clock.now.Add(31 * time.Second)
+	l.take("c")
+	if _, ok := l.clients["a"]; ok {
+		t.Error("expected the idle client to be evicted")
+	}
+	if _, ok := l.clients["b"]; !ok {
+		t.Error("expected the client still refilling to be kept")
+	}
+}
+
+func TestKeyByHeader(t *testing.T) {
+	key := KeyByHeader("X-API-Key", func(v string) bool { return v == "secret" })
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := key(req); got != "ip:192.0.2.1" {
+		t.Errorf("expected the IP without an API key, got %q", got)
+	}
+	req.Header.Set("X-API-Key", "made-up")
+	if got := key(req); got != "ip:192.0.2.1" {
+		t.Errorf("expected the IP with an unknown API key, got %q", got)
+	}
+	req.Header.Set("X-API-Key", "secret")
+	if got := key(req); got != "header:secret" {
+		t.Errorf("expected the API key, got %q", got)
+	}
+}
+
+func TestKeyByPrincipal(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := KeyByPrincipal(req); got != "ip:192.0.2.1" {
+		t.Errorf("expected the IP without a principal, got %q", got)
+	}
+	req = req.WithContext(WithPrincipal(req.Context(), Principal{Subject: "alice"}))
+	if got := KeyByPrincipal(req); got != "principal:alice" {
+		t.Errorf("expected the principal, got %q", got)
+	}
+}
+
+func TestRateLimit_InvalidOptions(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a zero limit to panic")
+		}
+	}()
+	RateLimit(RateLimitOptions{Per: time.Second})
+}
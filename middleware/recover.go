@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panic in the next handler into a 500 ErrorResponse and
+// logs it with its stack trace. The panic value is not shown to the
+// client. If the handler already started the response it is left as is,
+// as its status can't be changed anymore.
+//
+// A panic with http.ErrAbortHandler is passed on, so the server aborts
+// the response as the handler asked.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+					panic(err)
+				}
+				logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+					slog.String("error", fmt.Sprint(err)),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", requestID(r)),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !rw.wroteHeader {
+					WriteError(rw, http.StatusInternalServerError, "internal server error")
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("Ups")
+	}))
+	req := httptest.NewRequest("GET", "/panic", nil)
+	req = req.WithContext(WithRequestID(req.Context(), "abc"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a 500 JSON response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var res ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res != (ErrorResponse{Code: 500, Message: "internal server error"}) {
+		t.Errorf("unexpected response %+v", res)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["error"] != "Ups" || record["request_id"] != "abc" || !strings.Contains(fmt.Sprint(record["stack"]), "TestRecover") {
+		t.Errorf("expected the panic to be logged with its stack, got %v", record)
+	}
+}
+
+func TestRecover_AfterWrite(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	h := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "partial")
+		panic("Ups")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+		t.Errorf("expected the started response to be left alone, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecover_Abort(t *testing.T) {
+	var buf bytes.Buffer
+	h := Recover(slog.New(slog.NewTextHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be passed on, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected an abort not to be logged, got %q", buf.String())
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header a request ID is read from and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the IDs accepted from clients, so they can't
+// flood the logs.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, the one in its X-Request-ID header
+// or a new random one, puts it in the request context and echoes it in
+// the response. It must come before the middlewares that log the ID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "" if there is
+// none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestID returns the ID RequestID gave the request.
+func requestID(r *http.Request) string {
+	return RequestIDFromContext(r.Context())
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII that fit in a
+// log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Transport returns a RoundTripper that passes the request ID in the
+// context of an outgoing request on in its X-Request-ID header, so calls
+// made while handling a request can be traced back to it. A nil base
+// uses http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id := RequestIDFromContext(req.Context())
+		if id == "" || req.Header.Get(RequestIDHeader) != "" {
+			return base.RoundTrip(req)
+		}
+		// a RoundTripper must not change the request it was given
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
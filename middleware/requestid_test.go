@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"has space", false},
+		{strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if tt.keep && got != tt.header {
+			t.Errorf("expected %q to be kept, got %q", tt.header, got)
+		}
+		if !tt.keep && (got == tt.header || len(got) != 32) {
+			t.Errorf("expected a new ID instead of %q, got %q", tt.header, got)
+		}
+		if echoed := rec.Header().Get(RequestIDHeader); echoed != got {
+			t.Errorf("expected the ID %q to be echoed, got %q", got, echoed)
+		}
+	}
+}
+
+func TestRequestID_Chain(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := NewLogger(&buf, "json")
+	h := Chain(RequestID, Log(logger))(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	var record map[string]any
+	json.Unmarshal(buf.Bytes(), &record)
+	if id := rec.Header().Get(RequestIDHeader); id == "" || record["request_id"] != id {
+		t.Errorf("expected the log to have the request ID %q, got %v", id, record["request_id"])
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(RequestIDHeader))
+	}))
+	defer backend.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	// a handler calling another service passes its request ID on
+	front := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if req.Header.Get(RequestIDHeader) != "" {
+			t.Error("expected the outgoing request not to be changed")
+		}
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	front.ServeHTTP(httptest.NewRecorder(), req)
+
+	res, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if len(got) != 2 || got[0] != "abc" || got[1] != "" {
+		t.Errorf("expected the request ID only on the call made for a request, got %q", got)
+	}
+}
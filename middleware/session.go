@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by a SessionStore for an unknown or
+// expired session.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRecord is a session as kept by a SessionStore.
+type SessionRecord struct {
+	Values   map[string]string `json:"values"`
+	Created  time.Time         `json:"created"`
+	LastSeen time.Time         `json:"lastSeen"`
+	// Expires is when the store may forget the session.
+	Expires time.Time `json:"expires"`
+}
+
+// SessionStore keeps sessions by ID on the server.
+type SessionStore interface {
+	Load(id string) (SessionRecord, error)
+	Save(id string, r SessionRecord) error
+	Delete(id string) error
+}
+
+// SessionOptions configures a SessionManager.
+type SessionOptions struct {
+	Store SessionStore
+	// CookieName is "sid" if empty.
+	CookieName string
+	// IdleTimeout ends sessions unused for that long, 30 minutes if zero.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions that old however busy they are, 24
+	// hours if zero.
+	AbsoluteTimeout time.Duration
+	// Insecure allows the cookie over plain HTTP, for local development.
+	Insecure bool
+}
+
+// SessionManager keeps session data on the server, found by a random ID
+// in a cookie.
+type SessionManager struct {
+	store    SessionStore
+	cookie   string
+	idle     time.Duration
+	absolute time.Duration
+	insecure bool
+	now      func() time.Time
+}
+
+// NewSessionManager returns a manager keeping sessions in opts.Store. It
+// panics if there is no store.
+func NewSessionManager(opts SessionOptions) *SessionManager {
+	if opts.Store == nil {
+		panic("middleware: NewSessionManager needs a Store")
+	}
+	m := &SessionManager{
+		store:    opts.Store,
+		cookie:   opts.CookieName,
+		idle:     opts.IdleTimeout,
+		absolute: opts.AbsoluteTimeout,
+		insecure: opts.Insecure,
+		now:      time.Now,
+	}
+	if m.cookie == "" {
+		m.cookie = "sid"
+	}
+	if m.idle == 0 {
+		m.idle = 30 * time.Minute
+	}
+	if m.absolute == 0 {
+		m.absolute = 24 * time.Hour
+	}
+	return m
+}
+
+// Session is the session of a request. It is safe for concurrent use by
+// the goroutines handling the request.
+type Session struct {
+	mu        sync.Mutex
+	id        string
+	oldID     string
+	record    SessionRecord
+	stored    bool
+	changed   bool
+	destroyed bool
+}
+
+// SessionFromContext returns the session the SessionManager middleware
+// put in ctx, or nil.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey).(*Session)
+	return s
+}
+
+// Get returns the value of key, or "" if it isn't set.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record.Values[key]
+}
+
+// Set sets key to value.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record.Values == nil {
+		s.record.Values = make(map[string]string)
+	}
+	s.record.Values[key] = value
+	s.changed = true
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.record.Values, key)
+	s.changed = true
+}
+
+// RenewID gives the session a new ID and drops the old one. Call it when
+// the user logs in or gains privileges, so an ID planted before that is
+// worthless.
+func (s *Session) RenewID() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldID == "" && s.stored {
+		s.oldID = s.id
+	}
+	s.id = newSessionID()
+	s.changed = true
+}
+
+// Destroy ends the session, on logout for example.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+}
+
+// Middleware loads the session of each request into its context and
+// saves it before the response is written. A new session is only stored
+// once something is set in it.
+func (m *SessionManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.load(r)
+		sw := &sessionWriter{ResponseWriter: w, commit: func() {
+			if err := m.save(w, s); err != nil {
+				log.Printf("error saving session: %v", err)
+			}
+		}}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionKey, s)))
+		sw.commitOnce()
+	})
+}
+
+func (m *SessionManager) load(r *http.Request) *Session {
+	now := m.now()
+	if c, err := r.Cookie(m.cookie); err == nil {
+		record, err := m.store.Load(c.Value)
+		switch {
+		case err == nil && now.Before(m.expires(record)):
+			record.LastSeen = now
+			return &Session{id: c.Value, record: record, stored: true}
+		case err == nil:
+			// expired sessions are removed rather than renewed
+			m.store.Delete(c.Value)
+		case !errors.Is(err, ErrSessionNotFound):
+			log.Printf("error loading session: %v", err)
+		}
+	}
+	return &Session{
+		id:     newSessionID(),
+		record: SessionRecord{Created: now, LastSeen: now},
+	}
+}
+
+// save stores the session and sets its cookie, or removes both if it
+// was destroyed.
+func (m *SessionManager) save(w http.ResponseWriter, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldID != "" {
+		if err := m.store.Delete(s.oldID); err != nil {
+			return err
+		}
+	}
+	if s.destroyed {
+		http.SetCookie(w, m.newCookie("", -1))
+		if !s.stored {
+			return nil
+		}
+		return m.store.Delete(s.id)
+	}
+	if !s.stored && !s.changed {
+		return nil
+	}
+
+	s.record.Expires = m.expires(s.record)
+	record := s.record
+	record.Values = maps.Clone(s.record.Values)
+	if err := m.store.Save(s.id, record); err != nil {
+		return err
+	}
+	maxAge := int(s.record.Created.Add(m.absolute).Sub(m.now()).Seconds())
+	http.SetCookie(w, m.newCookie(s.id, maxAge))
+	return nil
+}
+
+// expires returns when a session times out, whichever of its timeouts
+// comes first.
+func (m *SessionManager) expires(r SessionRecord) time.Time {
+	idle := r.LastSeen.Add(m.idle)
+	absolute := r.Created.Add(m.absolute)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+func (m *SessionManager) newCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   !m.insecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sessionWriter saves the session right before the response starts, as
+// its cookie can't be set after that.
+type sessionWriter struct {
+	http.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+func (w *sessionWriter) commitOnce() {
+	if !w.committed {
+		w.committed = true
+		w.commit()
+	}
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commitOnce()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commitOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commitOnce()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore keeps sessions in memory. They are lost when the
+// process exits.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]SessionRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]SessionRecord),
+		now:      time.Now,
+	}
+}
+
+func (m *MemorySessionStore) Load(id string) (SessionRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.sessions[id]
+	if !ok || !m.now().Before(r.Expires) {
+		return SessionRecord{}, ErrSessionNotFound
+	}
+	r.Values = maps.Clone(r.Values)
+	return r, nil
+}
+
+// Save stores the session, dropping the expired ones every minute or so
+// to keep memory bounded.
+func (m *MemorySessionStore) Save(id string, r SessionRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		m.lastSweep = now
+		for id, r := range m.sessions {
+			if !now.Before(r.Expires) {
+				delete(m.sessions, id)
+			}
+		}
+	}
+	r.Values = maps.Clone(r.Values)
+	m.sessions[id] = r
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// FileSessionStore keeps each session in a JSON file of its own in a
+// directory, so sessions survive a restart.
+type FileSessionStore struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileSessionStore returns a store keeping sessions in dir, creating it
+// if needed.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating session directory: %w", err)
+	}
+	return &FileSessionStore{
+		dir: dir,
+		now: time.Now,
+	}, nil
+}
+
+func (f *FileSessionStore) Load(id string) (SessionRecord, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return SessionRecord{}, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return SessionRecord{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return SessionRecord{}, err
+	}
+	var r SessionRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return SessionRecord{}, fmt.Errorf("error decoding session: %w", err)
+	}
+	if !f.now().Before(r.Expires) {
+		os.Remove(path)
+		return SessionRecord{}, ErrSessionNotFound
+	}
+	return r, nil
+}
+
+// Save writes the session to a temporary file and renames it into place,
+// so a crash mid-write leaves the previous version intact.
+func (f *FileSessionStore) Save(id string, r SessionRecord) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileSessionStore) Delete(id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes the files of expired sessions. Run it now and then, as
+// expired sessions are otherwise only removed when they come back.
+func (f *FileSessionStore) Cleanup() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validSessionID(id) {
+			continue
+		}
+		// Load removes the file if the session expired
+		if _, err := f.Load(id); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file of a session. IDs come from cookies, so anything
+// but the IDs newSessionID makes is refused to keep them from naming
+// files outside the directory.
+func (f *FileSessionStore) path(id string) (string, error) {
+	if !validSessionID(id) {
+		return "", ErrSessionNotFound
+	}
+	return filepath.Join(f.dir, id+".json"), nil
+}
+
+func validSessionID(id string) bool {
+	if len(id) != 43 {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
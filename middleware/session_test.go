@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sessionApp counts visits, logs in and out, and keeps the session
+// cookie like a browser would.
+type sessionApp struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+}
+
+func newSessionApp(t *testing.T, m *SessionManager) *sessionApp {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/visit", func(w http.ResponseWriter, r *http.Request) {
+		s := SessionFromContext(r.Context())
+		var n int
+		fmt.Sscan(s.Get("visits"), &n)
+		s.Set("visits", fmt.Sprint(n+1))
+		fmt.Fprint(w, n+1)
+	})
+	mux.HandleFunc("/peek", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, SessionFromContext(r.Context()).Get("user"))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		s := SessionFromContext(r.Context())
+		s.RenewID()
+		s.Set("user", "billy")
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		SessionFromContext(r.Context()).Destroy()
+	})
+	return &sessionApp{t: t, handler: m.Middleware(mux)}
+}
+
+func (a *sessionApp) get(path string) string {
+	req := httptest.NewRequest("GET", path, nil)
+	if a.cookie != nil {
+		req.AddCookie(a.cookie)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge < 0 {
+			a.cookie = nil
+		} else {
+			a.cookie = c
+		}
+	}
+	return rec.Body.String()
+}
+
+func TestSessionManager(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]SessionStore{
+		"memory": NewMemorySessionStore(),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)}
+			switch s := store.(type) {
+			case *MemorySessionStore:
+				s.now = clock.Now
+			case *FileSessionStore:
+				s.now = clock.Now
+			}
+			m := NewSessionManager(SessionOptions{Store: store, IdleTimeout: 10 * time.Minute, AbsoluteTimeout: time.Hour})
+			m.now = clock.Now
+			app := newSessionApp(t, m)
+
+			if app.get("/peek"); app.cookie != nil {
+				t.Error("expected no cookie for an untouched session")
+			}
+			app.get("/visit")
+			first := app.cookie
+			if first == nil || !first.HttpOnly || !first.Secure || first.SameSite != http.SameSiteLaxMode || first.MaxAge != 3600 {
+				t.Fatalf("unexpected cookie %+v", first)
+			}
+			if got := app.get("/visit"); got != "2" {
+				t.Errorf("expected the visits to be kept, got %s", got)
+			}
+
+			app.get("/login")
+			if app.cookie.Value == first.Value {
+				t.Error("expected logging in to change the session ID")
+			}
+			if _, err := store.Load(first.Value); err != ErrSessionNotFound {
+				t.Errorf("expected the old ID to be gone, got %v", err)
+			}
+			if got := app.get("/visit"); got != "3" {
+				t.Errorf("expected the data to survive the new ID, got %s", got)
+			}
+
+			// idle timeout
+			clock.now = clock.now.Add(9 * time.Minute)
+			if got := app.get("/peek"); got != "billy" {
+				t.Errorf("expected the session to be alive, got %q", got)
+			}
+			clock.now = clock.now.Add(11 * time.Minute)
+			if got := app.get("/visit"); got != "1" {
+				t.Errorf("expected an idle session to time out, got %s", got)
+			}
+
+			// absolute timeout, however busy the session is
+			for range 6 {
+				clock.now = clock.now.Add(9 * time.Minute)
+				app.get("/visit")
+			}
+			clock.now = clock.now.Add(9 * time.Minute)
+			if got := app.get("/visit"); got != "1" {
+				t.Errorf("expected an old session to time out, got %s", got)
+			}
+
+			app.get("/logout")
+			if app.cookie != nil {
+				t.Error("expected logging out to remove the cookie")
+			}
+		})
+	}
+
+	// a cookie can't name a file outside the directory
+	if _, err := fileStore.Load("../../etc/passwd"); err != ErrSessionNotFound {
+		t.Errorf("expected an invalid ID to be refused, got %v", err)
+	}
+}
+
+func TestFileSessionStore_Cleanup(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileSessionStore(dir)
+	now := time.Now()
+	old, fresh := newSessionID(), newSessionID()
+	store.Save(old, SessionRecord{Expires: now.Add(-time.Minute)})
+	store.Save(fresh, SessionRecord{Expires: now.Add(time.Minute)})
+	if err := store.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, old+".json")); !os.IsNotExist(err) {
+		t.Error("expected the expired session to be removed")
+	}
+	if _, err := store.Load(fresh); err != nil {
+		t.Errorf("expected the fresh session to be kept, got %v", err)
+	}
+}
@@ -2,36 +2,12 @@ package learning
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"testing"
-)
-
-type LogMiddleware struct {
-	Handler http.Handler
-}
-
-type ErrorHandler struct {
-	Handler http.Handler
-}
-
-func (middleware *LogMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	fmt.Println("Before execute handler")
-	middleware.Handler.ServeHTTP(writer, request)
-	fmt.Println("After execute handler")
-}
 
-func (errorHandler ErrorHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fmt.Println("Terjadi error")
-			writer.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(writer, "Error %s", err)
-		}
-	}()
-
-	errorHandler.Handler.ServeHTTP(writer, request)
-}
+	"github.com/billykore/go/learning/middleware"
+)
 
 func TestMiddleware(t *testing.T) {
 	mux := http.NewServeMux()
@@ -51,17 +27,9 @@ func TestMiddleware(t *testing.T) {
 		panic("Ups")
 	})
 
-	logMiddleware := &LogMiddleware{
-		Handler: mux,
-	}
-
-	errorHandler := &ErrorHandler{
-		Handler: logMiddleware,
-	}
-
 	server := http.Server{
 		Addr:    "localhost:8080",
-		Handler: errorHandler,
+		Handler: middleware.Chain(middleware.RequestID, middleware.Log(slog.Default()), middleware.Recover(slog.Default()))(mux),
 	}
 
 	err := server.ListenAndServe()
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit codes of the non-interactive commands.
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+)
+
+const usageText = `Usage: todo [-file path] [-list name] [command]
+
+Without a command the interactive menu is started.
+
+Commands:
+  add --title <title> [--desc <description>] [--due <date>] [--repeat <rule>] [--priority <priority>] [--tags <tags>] [--parent <id>]
+  list [--tag <tag>] [--status all|open|done] [--overdue] [--sort due|priority]
+  edit <id> [--title <title>] [--desc <description>] [--due <date>] [--repeat <rule>] [--priority <priority>] [--tags <tags>]
+  done [--all] <id>
+  undone <id>
+  rm [--subtasks delete|keep] <id>
+  reset
+  export [--format csv|json]
+  import <file.csv>
+  serve [--addr <address>] [--remind <durations>] [--webhook <url>]
+`
+
+// runCommand runs a single command against the list and returns the
+// process exit code.
+func runCommand(l *todoList, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usageText)
+		return exitUsage
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "add":
+		return addCommand(l, args)
+	case "list":
+		return listCommand(l, args)
+	case "edit":
+		return editCommand(l, args)
+	case "done":
+		return doneCommand(l, args)
+	case "undone":
+		return idCommand("undone", args, l.uncompleteTodo)
+	case "rm":
+		return rmCommand(l, args)
+	case "reset":
+		return resetCommand(l, args)
+	case "export":
+		return exportCommand(l, args)
+	case "import":
+		return importCommand(l, args)
+	case "serve":
+		return serveCommand(l, args)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usageText)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usageText)
+		return exitUsage
+	}
+}
+
+func addCommand(l *todoList, args []string) int {
+	fs := newFlagSet("add --title <title> [--desc <description>] [--due <date>] [--repeat <rule>] [--priority <priority>] [--tags <tags>] [--parent <id>]")
+	title := fs.String("title", "", "title of the todo")
+	desc := fs.String("desc", "", "description of the todo")
+	strDue := fs.String("due", "", "due date as YYYY-MM-DD or \"YYYY-MM-DD HH:MM\"")
+	strRepeat := fs.String("repeat", "", "daily, weekly, \"monthly <day>\" or \"every <n> days\"")
+	strPriority := fs.String("priority", "", "low, medium or high")
+	tags := fs.String("tags", "", "comma separated tags")
+	parent := fs.Int("parent", 0, "ID of the todo to add a subtask to")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *title == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	due, err := parseDue(*strDue)
+	if err != nil {
+		return commandError("add", err)
+	}
+	repeat, err := parseRecurrence(*strRepeat, due)
+	if err != nil {
+		return commandError("add", err)
+	}
+	p, err := parsePriority(*strPriority)
+	if err != nil {
+		return commandError("add", err)
+	}
+	t := newTodo(*title, *desc)
+	t.due = due
+	t.recurrence = repeat
+	t.priority = p
+	t.tags = parseTags(*tags)
+	t.parentID = *parent
+	if err := l.addTodos(t); err != nil {
+		return commandError("add", err)
+	}
+	fmt.Println(t.id)
+	return exitOK
+}
+
+// editCommand changes only the fields given as flags. An empty value
+// clears the field.
+func editCommand(l *todoList, args []string) int {
+	fs := newFlagSet("edit <id> [--title <title>] [--desc <description>] [--due <date>] [--repeat <rule>] [--priority <priority>] [--tags <tags>]")
+	title := fs.String("title", "", "new title")
+	desc := fs.String("desc", "", "new description")
+	strDue := fs.String("due", "", "new due date as YYYY-MM-DD or \"YYYY-MM-DD HH:MM\"")
+	strRepeat := fs.String("repeat", "", "new recurrence, daily, weekly, \"monthly <day>\" or \"every <n> days\"")
+	strPriority := fs.String("priority", "", "new priority, low, medium or high")
+	strTags := fs.String("tags", "", "new comma separated tags")
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	// the ID comes first, the flag package stops parsing at positional arguments
+	strId, args := args[0], args[1:]
+	if !parseArgs(fs, args, 0) {
+		return exitUsage
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		return commandError("edit", err)
+	}
+
+	t, err := l.getTodo(id)
+	if err != nil {
+		return commandError("edit", err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var e todoEdit
+	if set["title"] {
+		e.title = title
+	}
+	if set["desc"] {
+		e.description = desc
+	}
+	due := t.due
+	if set["due"] {
+		if due, err = parseDue(*strDue); err != nil {
+			return commandError("edit", err)
+		}
+		e.due = &due
+	}
+	if set["repeat"] {
+		// a monthly rule without a day repeats on the day of the new due date
+		repeat, err := parseRecurrence(*strRepeat, due)
+		if err != nil {
+			return commandError("edit", err)
+		}
+		e.recurrence = &repeat
+	}
+	if set["priority"] {
+		p, err := parsePriority(*strPriority)
+		if err != nil {
+			return commandError("edit", err)
+		}
+		e.priority = &p
+	}
+	if set["tags"] {
+		tags := parseTags(*strTags)
+		e.tags = &tags
+	}
+	if err := l.editTodo(id, e); err != nil {
+		return commandError("edit", err)
+	}
+	return exitOK
+}
+
+func listCommand(l *todoList, args []string) int {
+	fs := newFlagSet("list [--tag <tag>] [--status all|open|done] [--overdue] [--sort due|priority]")
+	tag := fs.String("tag", "", "only list todos with this tag")
+	strStatus := fs.String("status", "", "all, open or done")
+	overdue := fs.Bool("overdue", false, "only list overdue todos")
+	strSort := fs.String("sort", "", "due or priority")
+	if !parseArgs(fs, args, 0) {
+		return exitUsage
+	}
+	st, err := parseStatus(*strStatus)
+	if err != nil {
+		return commandError("list", err)
+	}
+	sortBy, err := parseSortKey(*strSort)
+	if err != nil {
+		return commandError("list", err)
+	}
+	opts := listOptions{
+		tag:     *tag,
+		status:  st,
+		overdue: *overdue,
+		sortBy:  sortBy,
+	}
+	printTodos(os.Stdout, l.listTodos(opts, time.Now()))
+	return exitOK
+}
+
+func idCommand(name string, args []string, f func(id int) error) int {
+	fs := newFlagSet(name + " <id>")
+	if !parseArgs(fs, args, 1) {
+		return exitUsage
+	}
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "todo %s: %v\n", name, err)
+		return exitUsage
+	}
+	if err := f(id); err != nil {
+		return commandError(name, err)
+	}
+	return exitOK
+}
+
+// doneCommand completes a todo, and with --all its open subtasks too.
+func doneCommand(l *todoList, args []string) int {
+	fs := newFlagSet("done [--all] <id>")
+	all := fs.Bool("all", false, "also complete the subtasks")
+	if !parseArgs(fs, args, 1) {
+		return exitUsage
+	}
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		return commandError("done", err)
+	}
+	if err := l.completeTodoWith(id, *all); err != nil {
+		return commandError("done", err)
+	}
+	return exitOK
+}
+
+// rmCommand removes a todo. A todo with subtasks needs --subtasks to say
+// what happens to them.
+func rmCommand(l *todoList, args []string) int {
+	fs := newFlagSet("rm [--subtasks delete|keep] <id>")
+	strPolicy := fs.String("subtasks", "", "delete or keep the subtasks of the todo")
+	if !parseArgs(fs, args, 1) {
+		return exitUsage
+	}
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		return commandError("rm", err)
+	}
+	policy, err := parseSubtaskPolicy(*strPolicy)
+	if err != nil {
+		return commandError("rm", err)
+	}
+	if err := l.removeTodoWith(id, policy); err != nil {
+		return commandError("rm", err)
+	}
+	return exitOK
+}
+
+func resetCommand(l *todoList, args []string) int {
+	fs := newFlagSet("reset")
+	if !parseArgs(fs, args, 0) {
+		return exitUsage
+	}
+	if err := l.reset(); err != nil {
+		return commandError("reset", err)
+	}
+	return exitOK
+}
+
+// serveCommand runs the REST API until the process is interrupted. Due
+// reminders are POSTed to the webhook, or logged if there is none.
+func serveCommand(l *todoList, args []string) int {
+	fs := newFlagSet("serve [--addr <address>] [--remind <durations>] [--webhook <url>]")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	remind := fs.String("remind", defaultReminders, "how long before the due time reminders are sent, empty for none")
+	webhook := fs.String("webhook", "", "URL reminders are POSTed to")
+	if !parseArgs(fs, args, 0) {
+		return exitUsage
+	}
+	offsets, err := parseReminders(*remind)
+	if err != nil {
+		return commandError("serve", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(offsets) > 0 {
+		var n notifier = newBannerNotifier(os.Stderr)
+		if *webhook != "" {
+			n = newWebhookNotifier(*webhook)
+		}
+		go newScheduler(l.store, offsets, n).run(ctx)
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: newServer(l).routes(),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	log.Printf("serving todos on http://%s", *addr)
+
+	select {
+	case err := <-errCh:
+		return commandError("serve", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return commandError("serve", err)
+	}
+	return exitOK
+}
+
+// newFlagSet returns a flag set for a command, usage being the command
+// line it expects.
+func newFlagSet(usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: todo %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags and reports whether exactly nArgs positional
+// arguments are left.
+func parseArgs(fs *flag.FlagSet, args []string, nArgs int) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	if fs.NArg() != nArgs {
+		fs.Usage()
+		return false
+	}
+	return true
+}
+
+func commandError(name string, err error) int {
+	fmt.Fprintf(os.Stderr, "todo %s: %v\n", name, err)
+	switch errorKind(err) {
+	case todoErrorKindNotFound:
+		return exitNotFound
+	case todoErrorKindInvalidID, todoErrorKindInvalidInput, todoErrorKindHasSubtasks:
+		return exitUsage
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"add", "--title", "first", "--desc", "one"}, exitOK},
+		{[]string{"add", "--title", "second"}, exitOK},
+		{[]string{"add", "--desc", "no title"}, exitUsage},
+		{[]string{"add", "--unknown"}, exitUsage},
+		{[]string{"add", "--title", "third", "--priority", "urgent"}, exitUsage},
+		{[]string{"add", "--title", "third", "--due", "someday"}, exitUsage},
+		{[]string{"list"}, exitOK},
+		{[]string{"list", "--tag", "work", "--status", "open", "--overdue", "--sort", "due"}, exitOK},
+		{[]string{"list", "--sort", "size"}, exitUsage},
+		{[]string{"done", "1"}, exitOK},
+		{[]string{"done", "42"}, exitNotFound},
+		{[]string{"done", "abc"}, exitUsage},
+		{[]string{"done"}, exitUsage},
+		{[]string{"undone", "1"}, exitOK},
+		{[]string{"edit", "1", "--desc", "", "--tags", "work,home"}, exitOK},
+		{[]string{"edit", "1", "--priority", "urgent"}, exitUsage},
+		{[]string{"edit", "abc", "--title", "x"}, exitUsage},
+		{[]string{"edit", "42", "--title", "x"}, exitNotFound},
+		{[]string{"done", "1"}, exitOK},
+		{[]string{"rm", "2"}, exitOK},
+		{[]string{"rm", "2"}, exitNotFound},
+		{[]string{"add", "--title", "subtask", "--parent", "1"}, exitOK},
+		{[]string{"add", "--title", "orphan", "--parent", "42"}, exitNotFound},
+		{[]string{"rm", "1"}, exitUsage},
+		{[]string{"rm", "--subtasks", "purge", "1"}, exitUsage},
+		{[]string{"done", "--all", "1"}, exitOK},
+		{[]string{"rm", "3"}, exitOK},
+		{[]string{"unknown"}, exitUsage},
+		{nil, exitUsage},
+	}
+	for _, tt := range tests {
+		// every command runs against a freshly loaded list like a new process would
+		if got := runCommand(newTestTodoList(t, path), tt.args); got != tt.want {
+			t.Errorf("runCommand(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+
+	list := newTestTodoList(t, path)
+	if len(list.todos) != 1 {
+		t.Fatalf("expected 1 todo, got %d", len(list.todos))
+	}
+	if td := list.todos[0]; td.id != 1 || td.title != "first" || td.description != "" || !td.completed || !slices.Equal(td.tags, []string{"work", "home"}) {
+		t.Errorf("unexpected todo %+v", *td)
+	}
+
+	if got := runCommand(list, []string{"reset"}); got != exitOK {
+		t.Errorf("reset = %d, want %d", got, exitOK)
+	}
+	if len(newTestTodoList(t, path).todos) != 0 {
+		t.Error("expected 0 todos after reset")
+	}
+}
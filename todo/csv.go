@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// marshal and unmarshal are the csv tag driven marshaller from the
+// "Learning Go" examples in the repository root, changed so a bad row is
+// reported instead of aborting the whole file.
+
+// marshal maps all structs in a slice of structs to a slice of slice of strings.
+// The first row written is the header with the column names.
+func marshal(v any) ([][]string, error) {
+	sliceVal := reflect.ValueOf(v)
+	if sliceVal.Kind() != reflect.Slice {
+		return nil, errors.New("must be a slice of structs")
+	}
+	structType := sliceVal.Type().Elem()
+	if structType.Kind() != reflect.Struct {
+		return nil, errors.New("must be a slice of structs")
+	}
+	var out [][]string
+	header := marshalHeader(structType)
+	out = append(out, header)
+	for i := 0; i < sliceVal.Len(); i++ {
+		row, err := marshalOne(sliceVal.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+func marshalHeader(vt reflect.Type) []string {
+	var row []string
+	for i := 0; i < vt.NumField(); i++ {
+		field := vt.Field(i)
+		if curTag, ok := field.Tag.Lookup("csv"); ok {
+			row = append(row, curTag)
+		}
+	}
+	return row
+}
+
+func marshalOne(vv reflect.Value) ([]string, error) {
+	var row []string
+	vt := vv.Type()
+	for i := 0; i < vv.NumField(); i++ {
+		fieldVal := vv.Field(i)
+		if _, ok := vt.Field(i).Tag.Lookup("csv"); !ok {
+			continue
+		}
+		switch fieldVal.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			row = append(row, strconv.FormatInt(fieldVal.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			row = append(row, strconv.FormatUint(fieldVal.Uint(), 10))
+		case reflect.String:
+			row = append(row, fieldVal.String())
+		case reflect.Bool:
+			row = append(row, strconv.FormatBool(fieldVal.Bool()))
+		default:
+			return nil, fmt.Errorf("cannot handle field of kind %v", fieldVal.Kind())
+		}
+	}
+	return row, nil
+}
+
+// rowError is an error in a single row of CSV data. Rows are numbered
+// from 1, the header being row 1.
+type rowError struct {
+	row int
+	err error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.row, e.err)
+}
+
+func (e *rowError) Unwrap() error {
+	return e.err
+}
+
+// unmarshal maps all the rows of data in slice of slice of strings into a slice of structs.
+// The first row is assumed to be the header with the column names. Rows
+// that can't be mapped are skipped and returned as row errors, the error
+// is for data that can't be used at all.
+func unmarshal(data [][]string, v any) ([]*rowError, error) {
+	sliceValPtr := reflect.ValueOf(v)
+	if sliceValPtr.Kind() != reflect.Ptr {
+		return nil, errors.New("must be a pointer to a slice of structs")
+	}
+	sliceVal := sliceValPtr.Elem()
+	if sliceVal.Kind() != reflect.Slice {
+		return nil, errors.New("must be a pointer to a slice of structs")
+	}
+	structType := sliceVal.Type().Elem()
+	if structType.Kind() != reflect.Struct {
+		return nil, errors.New("must be a pointer to a slice of structs")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	// assume the first row is a header
+	header := data[0]
+	namePos := make(map[string]int, len(header))
+	for k, v := range header {
+		namePos[v] = k
+	}
+
+	var rowErrs []*rowError
+	for i, row := range data[1:] {
+		newVal := reflect.New(structType).Elem()
+		err := unmarshalOne(row, namePos, newVal)
+		if err != nil {
+			rowErrs = append(rowErrs, &rowError{row: i + 2, err: err})
+			continue
+		}
+		sliceVal.Set(reflect.Append(sliceVal, newVal))
+	}
+	return rowErrs, nil
+}
+
+func unmarshalOne(row []string, namePos map[string]int, vv reflect.Value) error {
+	vt := vv.Type()
+	for i := 0; i < vv.NumField(); i++ {
+		typeField := vt.Field(i)
+		name := typeField.Tag.Get("csv")
+		pos, ok := namePos[name]
+		if !ok {
+			continue
+		}
+		if pos >= len(row) {
+			return fmt.Errorf("missing column %q", name)
+		}
+		val := row[pos]
+		if val == "" {
+			// empty cells keep the zero value
+			continue
+		}
+		field := vv.Field(i)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a number", name, val)
+			}
+			field.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			i, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a number", name, val)
+			}
+			field.SetUint(i)
+		case reflect.String:
+			field.SetString(val)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a boolean", name, val)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("cannot handle field of kind %v", field.Kind())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+type todoErrorKind uint8
+
+const (
+	todoErrorKindNotFound todoErrorKind = iota + 1
+	todoErrorKindInvalidID
+	todoErrorKindInvalidInput
+	todoErrorKindInvalidOption
+	todoErrorKindEmptyHistory
+	todoErrorKindHasSubtasks
+)
+
+// todoError is returned for requests the user can correct, as opposed to
+// failures such as an unwritable storage file.
+type todoError struct {
+	kind    todoErrorKind
+	message string
+}
+
+var _ error = &todoError{}
+
+func (e *todoError) Kind() todoErrorKind {
+	if e != nil {
+		return e.kind
+	}
+	return 0
+}
+
+func (e *todoError) Error() string {
+	if e != nil {
+		return e.message
+	}
+	return ""
+}
+
+func notFoundError(id int) error {
+	return &todoError{todoErrorKindNotFound, fmt.Sprintf("no todo with ID %d", id)}
+}
+
+func listNotFoundError(name string) error {
+	return &todoError{todoErrorKindNotFound, fmt.Sprintf("no list named %q", name)}
+}
+
+func invalidIDError(input string) error {
+	return &todoError{todoErrorKindInvalidID, fmt.Sprintf("%q is not a valid ID", input)}
+}
+
+func invalidInputError(message string) error {
+	return &todoError{todoErrorKindInvalidInput, message}
+}
+
+func invalidOptionError(option string) error {
+	return &todoError{todoErrorKindInvalidOption, fmt.Sprintf("%q is not a valid option", option)}
+}
+
+func emptyHistoryError(action string) error {
+	return &todoError{todoErrorKindEmptyHistory, fmt.Sprintf("there is nothing to %s", action)}
+}
+
+func hasSubtasksError(id, n int) error {
+	return &todoError{todoErrorKindHasSubtasks, fmt.Sprintf("todo %d has %d subtasks, say whether to delete or keep them", id, n)}
+}
+
+// errorKind returns the kind of the todoError in err's chain, or 0 if
+// there is none.
+func errorKind(err error) todoErrorKind {
+	var te *todoError
+	if errors.As(err, &te) {
+		return te.Kind()
+	}
+	return 0
+}
+
+// parseID parses a user-typed todo ID.
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, invalidIDError(s)
+	}
+	return id, nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// csvTodo is a todo as a row of a CSV file. Parent is the ID of the row
+// of the parent of a subtask, 0 for top-level todos.
+type csvTodo struct {
+	ID          int    `csv:"id"`
+	Parent      int    `csv:"parent"`
+	Title       string `csv:"title"`
+	Description string `csv:"description"`
+	Completed   bool   `csv:"completed"`
+	Due         string `csv:"due"`
+	Recurrence  string `csv:"recurrence"`
+	Priority    string `csv:"priority"`
+	Tags        string `csv:"tags"`
+}
+
+func (t *todo) csv() csvTodo {
+	c := csvTodo{
+		ID:          t.id,
+		Parent:      t.parentID,
+		Title:       t.title,
+		Description: t.description,
+		Completed:   t.completed,
+		Recurrence:  t.recurrence.String(),
+		Priority:    t.priority.String(),
+		Tags:        strings.Join(t.tags, ","),
+	}
+	if !t.due.IsZero() {
+		c.Due = formatDue(t.due)
+	}
+	return c
+}
+
+// todo converts the row into a new todo. The ID and parent are left for
+// the import to assign, as the list gives the todos new IDs.
+func (c csvTodo) todo() (*todo, error) {
+	due, err := parseDue(c.Due)
+	if err != nil {
+		return nil, err
+	}
+	repeat, err := parseRecurrence(c.Recurrence, due)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePriority(c.Priority)
+	if err != nil {
+		return nil, err
+	}
+	t := newTodo(c.Title, c.Description)
+	t.completed = c.Completed
+	t.due = due
+	t.recurrence = repeat
+	t.priority = p
+	t.tags = parseTags(c.Tags)
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// exportTodos writes all todos to w as csv or json.
+func exportTodos(w io.Writer, l *todoList, format string) error {
+	todos := l.listTodos(listOptions{}, time.Now())
+	switch format {
+	case "csv":
+		rows := make([]csvTodo, 0, len(todos))
+		for _, t := range todos {
+			rows = append(rows, t.csv())
+		}
+		data, err := marshal(rows)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(data); err != nil {
+			return fmt.Errorf("error writing CSV: %w", err)
+		}
+		return nil
+	case "json":
+		records := make([]todoRecord, 0, len(todos))
+		for _, t := range todos {
+			records = append(records, t.record())
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	default:
+		return invalidInputError(fmt.Sprintf("%q is not a valid format, use csv or json", format))
+	}
+}
+
+// importTodos adds the todos in the CSV read from r as new todos. Rows
+// that can't be imported are returned as row errors and the rest are
+// still added. Subtasks are added under the todo of their parent row, so
+// an export imports with the same subtasks.
+func importTodos(r io.Reader, l *todoList) (int, []*rowError, error) {
+	cr := csv.NewReader(r)
+	// short rows are reported per row instead of failing the whole file
+	cr.FieldsPerRecord = -1
+	data, err := cr.ReadAll()
+	if err != nil {
+		return 0, nil, invalidInputError(fmt.Sprintf("invalid CSV: %v", err))
+	}
+	if len(data) == 0 || !slices.Contains(data[0], "title") {
+		return 0, nil, invalidInputError(`missing "title" column in the header row`)
+	}
+
+	var rows []csvTodo
+	rowErrs, err := unmarshal(data, &rows)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// unmarshal skips bad rows, so line the rows up with their row numbers
+	// again to report conversion errors
+	var todos []*todo
+	failed := make(map[int]bool, len(rowErrs))
+	for _, e := range rowErrs {
+		failed[e.row] = true
+	}
+	todoOf := make(map[int]*todo)
+	parentRow := make(map[*todo]int)
+	rowOf := make(map[*todo]int)
+	rowNum := 1
+	for _, c := range rows {
+		rowNum++
+		for failed[rowNum] {
+			rowNum++
+		}
+		t, err := c.todo()
+		if err != nil {
+			rowErrs = append(rowErrs, &rowError{row: rowNum, err: err})
+			continue
+		}
+		todos = append(todos, t)
+		rowOf[t] = rowNum
+		parentRow[t] = c.Parent
+		if c.ID != 0 {
+			todoOf[c.ID] = t
+		}
+	}
+
+	// the parents only get their new IDs once added, so the subtasks are
+	// linked to the todos of their parent rows
+	parents := make(map[*todo]*todo)
+	kept := todos[:0]
+	for _, t := range todos {
+		if id := parentRow[t]; id != 0 {
+			p, ok := todoOf[id]
+			if !ok {
+				rowErrs = append(rowErrs, &rowError{row: rowOf[t], err: invalidInputError(fmt.Sprintf("parent %d is not among the imported rows", id))})
+				continue
+			}
+			if parentRow[p] != 0 {
+				rowErrs = append(rowErrs, &rowError{row: rowOf[t], err: invalidInputError(fmt.Sprintf("todo %d is a subtask and cannot have subtasks of its own", id))})
+				continue
+			}
+			parents[t] = p
+		}
+		kept = append(kept, t)
+	}
+	todos = kept
+	slices.SortFunc(rowErrs, func(a, b *rowError) int { return a.row - b.row })
+
+	if len(todos) > 0 {
+		if err := l.addTodosWith(todos, parents); err != nil {
+			return 0, rowErrs, err
+		}
+	}
+	return len(todos), rowErrs, nil
+}
+
+func exportCommand(l *todoList, args []string) int {
+	fs := newFlagSet("export [--format csv|json]")
+	format := fs.String("format", "csv", "csv or json")
+	if !parseArgs(fs, args, 0) {
+		return exitUsage
+	}
+	if err := exportTodos(os.Stdout, l, *format); err != nil {
+		return commandError("export", err)
+	}
+	return exitOK
+}
+
+func importCommand(l *todoList, args []string) int {
+	fs := newFlagSet("import <file.csv>")
+	if !parseArgs(fs, args, 1) {
+		return exitUsage
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return commandError("import", err)
+	}
+	defer f.Close()
+
+	n, rowErrs, err := importTodos(f, l)
+	for _, e := range rowErrs {
+		fmt.Fprintf(os.Stderr, "todo import: %v\n", e)
+	}
+	if err != nil {
+		return commandError("import", err)
+	}
+	fmt.Printf("imported %d todos, %d rows failed\n", n, len(rowErrs))
+	if len(rowErrs) > 0 {
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportCSV(t *testing.T) {
+	td := newTodo("deploy", `v2, "final"`)
+	td.due = time.Date(2024, time.March, 1, 9, 30, 0, 0, time.Local)
+	td.priority = priorityHigh
+	td.tags = []string{"work", "release"}
+	list := newTestTodoList(t, "")
+	list.addTodos(td, newTodo("groceries", ""))
+	list.completeTodo(2)
+	milk := newTodo("milk", "")
+	milk.parentID = 2
+	list.addTodos(milk)
+
+	var buf bytes.Buffer
+	if err := exportTodos(&buf, list, "csv"); err != nil {
+		t.Fatalf("error exporting: %v", err)
+	}
+	want := `id,parent,title,description,completed,due,recurrence,priority,tags
+1,0,deploy,"v2, ""final""",false,2024-03-01 09:30,,high,"work,release"
+2,0,groceries,,true,,,,
+3,2,milk,,false,,,,
+`
+	if buf.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	other := newTestTodoList(t, "")
+	other.addTodos(newTodo("existing", ""))
+	n, rowErrs, err := importTodos(&buf, other)
+	if err != nil || len(rowErrs) != 0 || n != 3 {
+		t.Fatalf("unexpected import result %d, %v, %v", n, rowErrs, err)
+	}
+	imported := other.todos[1:]
+	if !equalTodo(imported[0], &todo{id: 2, title: td.title, description: td.description, due: td.due, priority: td.priority, tags: td.tags}) {
+		t.Errorf("unexpected todo %+v", *imported[0])
+	}
+	if imported[1].id != 3 || !imported[1].completed {
+		t.Errorf("unexpected todo %+v", *imported[1])
+	}
+	if imported[2].id != 4 || imported[2].parentID != 3 {
+		t.Errorf("expected the subtask under the imported parent, got %+v", *imported[2])
+	}
+}
+
+func TestImportCSV_Subtasks(t *testing.T) {
+	data := `id,parent,title
+1,,pack
+2,1,boxes
+3,2,tape
+4,9,lost
+5,0,move
+`
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("existing", ""))
+	n, rowErrs, err := importTodos(strings.NewReader(data), list)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 3 || titles(list) != "existing,pack,boxes,move" {
+		t.Errorf("expected 3 todos imported, got %d: %q", n, titles(list))
+	}
+	if boxes := list.todos[2]; boxes.parentID != list.todos[1].id {
+		t.Errorf("expected boxes under pack, got parent %d", boxes.parentID)
+	}
+
+	want := []string{
+		`row 4: todo 2 is a subtask and cannot have subtasks of its own`,
+		`row 5: parent 9 is not among the imported rows`,
+	}
+	if len(rowErrs) != len(want) {
+		t.Fatalf("expected %d row errors, got %v", len(want), rowErrs)
+	}
+	for i, w := range want {
+		if rowErrs[i].Error() != w {
+			t.Errorf("expected %q, got %q", w, rowErrs[i])
+		}
+	}
+}
+
+func TestImportCSV_RowErrors(t *testing.T) {
+	data := `title,completed,due,priority
+ok,true,,
+bad bool,maybe,,
+short
+,false,,
+bad due,false,soon,
+bad priority,false,,urgent
+also ok,,2024-03-01,low
+`
+	list := newTestTodoList(t, "")
+	n, rowErrs, err := importTodos(strings.NewReader(data), list)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 2 || len(list.todos) != 2 || list.todos[1].title != "also ok" {
+		t.Errorf("expected the 2 good rows to be imported, got %d", n)
+	}
+
+	want := []string{
+		`row 3: column "completed": "maybe" is not a boolean`,
+		`row 4: missing column "completed"`,
+		`row 5: title must not be empty`,
+		`row 6: "soon" is not a valid due date, use YYYY-MM-DD or YYYY-MM-DD HH:MM`,
+		`row 7: "urgent" is not a valid priority, use low, medium or high`,
+	}
+	if len(rowErrs) != len(want) {
+		t.Fatalf("expected %d row errors, got %v", len(want), rowErrs)
+	}
+	for i, w := range want {
+		if rowErrs[i].Error() != w {
+			t.Errorf("expected %q, got %q", w, rowErrs[i])
+		}
+	}
+}
+
+func TestImportCSV_MissingTitleColumn(t *testing.T) {
+	list := newTestTodoList(t, "")
+	if _, _, err := importTodos(strings.NewReader("name\nfoo\n"), list); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("title", "description"))
+
+	var buf bytes.Buffer
+	if err := exportTodos(&buf, list, "json"); err != nil {
+		t.Fatalf("error exporting: %v", err)
+	}
+	var records []todoRecord
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("error decoding export: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != 1 || records[0].Title != "title" {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	if err := exportTodos(&buf, list, "xml"); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+}
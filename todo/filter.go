@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type status uint8
+
+const (
+	statusAll status = iota
+	statusOpen
+	statusDone
+)
+
+// parseStatus parses all, open or done. An empty string is all.
+func parseStatus(s string) (status, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "all":
+		return statusAll, nil
+	case "open":
+		return statusOpen, nil
+	case "done":
+		return statusDone, nil
+	default:
+		return statusAll, invalidInputError(fmt.Sprintf("%q is not a valid status, use all, open or done", s))
+	}
+}
+
+type sortKey uint8
+
+const (
+	sortByID sortKey = iota
+	sortByDue
+	sortByPriority
+)
+
+// parseSortKey parses due or priority. An empty string keeps the order
+// the todos were added in.
+func parseSortKey(s string) (sortKey, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "id":
+		return sortByID, nil
+	case "due":
+		return sortByDue, nil
+	case "priority":
+		return sortByPriority, nil
+	default:
+		return sortByID, invalidInputError(fmt.Sprintf("%q is not a valid sort order, use due or priority", s))
+	}
+}
+
+// listOptions selects and orders the todos returned by todoList.listTodos.
+type listOptions struct {
+	tag     string
+	status  status
+	overdue bool
+	sortBy  sortKey
+}
+
+func (o listOptions) match(t *todo, now time.Time) bool {
+	if o.tag != "" && !t.hasTag(o.tag) {
+		return false
+	}
+	if o.status == statusOpen && t.completed || o.status == statusDone && !t.completed {
+		return false
+	}
+	if o.overdue && !t.overdue(now) {
+		return false
+	}
+	return true
+}
+
+// sortTodos orders todos by the sort key. Todos without a due date or a
+// priority go last, ties keep their current order.
+func sortTodos(todos []*todo, by sortKey) {
+	switch by {
+	case sortByDue:
+		sort.SliceStable(todos, func(i, j int) bool {
+			a, b := todos[i].due, todos[j].due
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+	case sortByPriority:
+		sort.SliceStable(todos, func(i, j int) bool {
+			return todos[i].priority > todos[j].priority
+		})
+	}
+}
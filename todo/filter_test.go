@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTodoList_ListTodos(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.Local)
+
+	report := newTodo("report", "")
+	report.due = time.Date(2024, time.March, 9, 0, 0, 0, 0, time.Local)
+	report.priority = priorityLow
+	report.tags = []string{"work"}
+
+	deploy := newTodo("deploy", "")
+	deploy.due = time.Date(2024, time.March, 10, 0, 0, 0, 0, time.Local)
+	deploy.priority = priorityHigh
+	deploy.tags = []string{"Work", "release"}
+
+	groceries := newTodo("groceries", "")
+	groceries.tags = []string{"home"}
+
+	call := newTodo("call", "")
+	call.due = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.Local)
+	call.priority = priorityMedium
+
+	list := newTestTodoList(t, "")
+	list.addTodos(report, deploy, groceries, call)
+	list.completeTodo(call.id)
+
+	tests := []struct {
+		name string
+		opts listOptions
+		want []*todo
+	}{
+		{"all", listOptions{}, []*todo{report, deploy, groceries, call}},
+		{"tag is case insensitive", listOptions{tag: "work"}, []*todo{report, deploy}},
+		{"open", listOptions{status: statusOpen}, []*todo{report, deploy, groceries}},
+		{"done", listOptions{status: statusDone}, []*todo{call}},
+		// deploy is due today without a time, so it is not overdue yet
+		{"overdue", listOptions{overdue: true}, []*todo{report}},
+		{"sort by due", listOptions{sortBy: sortByDue}, []*todo{report, deploy, call, groceries}},
+		{"sort by priority", listOptions{sortBy: sortByPriority}, []*todo{deploy, call, report, groceries}},
+		{"open work by priority", listOptions{tag: "work", status: statusOpen, sortBy: sortByPriority}, []*todo{deploy, report}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := list.listTodos(tt.opts, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d todos, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if got[i].id != tt.want[i].id {
+					t.Errorf("todo %d: expected %q, got %q", i, tt.want[i].title, got[i].title)
+				}
+			}
+		})
+	}
+}
+
+func TestTodo_Overdue(t *testing.T) {
+	td := newTodo("title", "")
+	td.due = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.Local)
+
+	if td.overdue(td.due.Add(-time.Minute)) {
+		t.Error("expected todo not to be overdue before its due time")
+	}
+	if !td.overdue(td.due) {
+		t.Error("expected todo to be overdue at its due time")
+	}
+	td.setCompleted(true)
+	if td.overdue(td.due.Add(time.Hour)) {
+		t.Error("expected a completed todo not to be overdue")
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	due, err := parseDue("2024-03-10 09:30")
+	if err != nil || !due.Equal(time.Date(2024, time.March, 10, 9, 30, 0, 0, time.Local)) {
+		t.Errorf("unexpected due %v, %v", due, err)
+	}
+	if formatDue(due) != "2024-03-10 09:30" {
+		t.Errorf("unexpected format %q", formatDue(due))
+	}
+	due, err = parseDue("2024-03-10")
+	if err != nil || formatDue(due) != "2024-03-10" {
+		t.Errorf("unexpected due %v, %v", due, err)
+	}
+	if due, err := parseDue(""); err != nil || !due.IsZero() {
+		t.Errorf("expected no due date, got %v, %v", due, err)
+	}
+	if _, err := parseDue("10/03/2024"); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// command is a reversible change to the todo list. do returns an error
+// without changing anything if the change is invalid. Other processes
+// may have changed the list since, so undo and redo only touch the todos
+// the command changed and skip those that are gone.
+type command interface {
+	do(l *todoList) error
+	undo(l *todoList)
+}
+
+// history keeps the commands run in this session so they can be undone
+// and redone.
+type history struct {
+	done   []command
+	undone []command
+}
+
+func (h *history) push(c command) {
+	h.done = append(h.done, c)
+	h.undone = nil
+}
+
+type addTodosCommand struct {
+	todos []*todo
+	// parents are the parents of the todos that are subtasks of others
+	// added with them.
+	parents map[*todo]*todo
+}
+
+// do assigns each todo the next free ID the first time it runs, so a redo
+// brings the todos back under the same IDs.
+func (c *addTodosCommand) do(l *todoList) error {
+	for _, t := range c.todos {
+		if err := t.validate(); err != nil {
+			return err
+		}
+		if p, ok := c.parents[t]; ok {
+			if p.parentID != 0 || c.parents[p] != nil {
+				return invalidInputError(fmt.Sprintf("%q is a subtask and cannot have subtasks of its own", p.title))
+			}
+			continue
+		}
+		if err := l.checkParent(t.parentID); err != nil {
+			return err
+		}
+	}
+	for _, t := range c.todos {
+		if t.id == 0 {
+			t.id = l.store.nextID
+			l.store.nextID++
+		}
+	}
+	for _, t := range c.todos {
+		if p, ok := c.parents[t]; ok {
+			t.parentID = p.id
+		}
+		l.insert(len(l.todos), t)
+	}
+	return nil
+}
+
+func (c *addTodosCommand) undo(l *todoList) {
+	for i := len(c.todos) - 1; i >= 0; i-- {
+		l.remove(c.todos[i])
+	}
+}
+
+// removeTodoCommand removes a todo. What happens to the subtasks of a
+// todo that has them is up to the policy.
+type removeTodoCommand struct {
+	id     int
+	policy subtaskPolicy
+	// removed are the todos removed, indexes where they were.
+	removed  []*todo
+	indexes  []int
+	detached []*todo
+}
+
+func (c *removeTodoCommand) do(l *todoList) error {
+	if c.removed == nil {
+		t, err := l.find(c.id)
+		if err != nil {
+			return err
+		}
+		children := l.children(t.id)
+		if len(children) > 0 && c.policy == subtasksRefuse {
+			return hasSubtasksError(t.id, len(children))
+		}
+		c.removed = []*todo{t}
+		for _, child := range children {
+			switch c.policy {
+			case subtasksDelete:
+				c.removed = append(c.removed, child)
+			case subtasksDetach:
+				c.detached = append(c.detached, child)
+			}
+		}
+	}
+
+	for _, child := range c.detached {
+		detached := *child
+		detached.parentID = 0
+		l.update(child, detached)
+	}
+	c.indexes = c.indexes[:0]
+	for _, t := range c.removed {
+		c.indexes = append(c.indexes, l.remove(t))
+	}
+	return nil
+}
+
+// undo puts the todos back where they were, last removed first.
+func (c *removeTodoCommand) undo(l *todoList) {
+	for i := len(c.removed) - 1; i >= 0; i-- {
+		if c.indexes[i] >= 0 {
+			l.insert(c.indexes[i], c.removed[i])
+		}
+	}
+	for _, child := range c.detached {
+		attached := *child
+		attached.parentID = c.id
+		l.update(child, attached)
+	}
+}
+
+// completeTodoCommand sets whether a todo is completed, along with its
+// subtasks if asked to. Completing the last open subtask completes its
+// parent, and completing a recurring todo adds its next occurrence.
+type completeTodoCommand struct {
+	id           int
+	completed    bool
+	withSubtasks bool
+	changes      []completion
+	// spawned are the next occurrences added.
+	spawned []*todo
+}
+
+func (c *completeTodoCommand) do(l *todoList) error {
+	if c.changes != nil {
+		for _, ch := range c.changes {
+			ch.after.apply(l, ch.todo)
+		}
+		for _, t := range c.spawned {
+			l.insert(len(l.todos), t)
+		}
+		return nil
+	}
+	t, err := l.find(c.id)
+	if err != nil {
+		return err
+	}
+
+	c.setCompleted(l, t, c.completed)
+	if c.withSubtasks {
+		for _, child := range l.children(t.id) {
+			c.setCompleted(l, child, c.completed)
+		}
+	}
+	if c.completed && t.parentID != 0 {
+		if parent, err := l.find(t.parentID); err == nil && !parent.completed && l.subtasksDone(parent.id) {
+			c.setCompleted(l, parent, true)
+		}
+	}
+	return nil
+}
+
+func (c *completeTodoCommand) setCompleted(l *todoList, t *todo, completed bool) {
+	before := completionOf(t)
+	if next := l.setCompleted(t, completed); next != nil {
+		c.spawned = append(c.spawned, next)
+	}
+	c.changes = append(c.changes, completion{todo: t, before: before, after: completionOf(t)})
+}
+
+func (c *completeTodoCommand) undo(l *todoList) {
+	for i := len(c.spawned) - 1; i >= 0; i-- {
+		l.remove(c.spawned[i])
+	}
+	for i := len(c.changes) - 1; i >= 0; i-- {
+		c.changes[i].before.apply(l, c.changes[i].todo)
+	}
+}
+
+// completion records the fields completing a todo changes, before and
+// after. Undo and redo set only these, so changes other processes made
+// to the rest of the todo are kept.
+type completion struct {
+	todo          *todo
+	before, after completionState
+}
+
+type completionState struct {
+	completed bool
+	// recurrence is handed on to the next occurrence.
+	recurrence recurrence
+}
+
+func completionOf(t *todo) completionState {
+	return completionState{completed: t.completed, recurrence: t.recurrence}
+}
+
+func (s completionState) apply(l *todoList, t *todo) {
+	v := *t
+	v.completed = s.completed
+	v.recurrence = s.recurrence
+	l.update(t, v)
+}
+
+// editTodoCommand edits a todo. Undo puts back only the fields the edit
+// set.
+type editTodoCommand struct {
+	id     int
+	edit   todoEdit
+	revert todoEdit
+}
+
+func (c *editTodoCommand) do(l *todoList) error {
+	t, err := l.find(c.id)
+	if err != nil {
+		return err
+	}
+	edited := *t
+	c.edit.apply(&edited)
+	if err := edited.validate(); err != nil {
+		return err
+	}
+	c.revert = c.edit.revert(t)
+	l.update(t, edited)
+	return nil
+}
+
+func (c *editTodoCommand) undo(l *todoList) {
+	if t, err := l.find(c.id); err == nil {
+		reverted := *t
+		c.revert.apply(&reverted)
+		l.update(t, reverted)
+	}
+}
+
+type resetListCommand struct {
+	todos []*todo
+}
+
+func (c *resetListCommand) do(l *todoList) error {
+	c.todos = slices.Clone(l.todos)
+	for i := len(c.todos) - 1; i >= 0; i-- {
+		l.remove(c.todos[i])
+	}
+	return nil
+}
+
+// undo puts the todos back in front of any added since.
+func (c *resetListCommand) undo(l *todoList) {
+	for i, t := range c.todos {
+		l.insert(i, t)
+	}
+}
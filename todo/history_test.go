@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func titles(l *todoList) string {
+	var s []string
+	for _, t := range l.todos {
+		title := t.title
+		if t.completed {
+			title += "*"
+		}
+		s = append(s, title)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestTodoList_UndoRedo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	list := newTestTodoList(t, path)
+
+	list.addTodos(newTodo("a", ""), newTodo("b", ""))
+	list.addTodos(newTodo("c", ""))
+	list.completeTodo(2)
+	list.removeTodo(1)
+	newTitle := "C"
+	list.editTodo(3, todoEdit{title: &newTitle})
+	list.reset()
+
+	steps := []struct {
+		do   func() error
+		want string
+	}{
+		{list.undo, "b*,C"},
+		{list.undo, "b*,c"},
+		{list.undo, "a,b*,c"},
+		{list.undo, "a,b,c"},
+		{list.redo, "a,b*,c"},
+		{list.undo, "a,b,c"},
+		{list.undo, "a,b"},
+		{list.undo, ""},
+		{list.redo, "a,b"},
+		{list.redo, "a,b,c"},
+	}
+	for i, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := titles(list); got != step.want {
+			t.Fatalf("step %d: expected %q, got %q", i, step.want, got)
+		}
+	}
+
+	if got := titles(newTestTodoList(t, path)); got != "a,b,c" {
+		t.Errorf("expected undo and redo to be saved, got %q", got)
+	}
+	if list.todos[2].id != 3 {
+		t.Errorf("expected redo to keep the ID, got %d", list.todos[2].id)
+	}
+
+	list.removeTodo(1)
+	if err := list.redo(); errorKind(err) != todoErrorKindEmptyHistory {
+		t.Errorf("expected a new command to clear the redo history, got %v", err)
+	}
+
+	fresh := newTestTodoList(t, "")
+	if err := fresh.undo(); errorKind(err) != todoErrorKindEmptyHistory {
+		t.Errorf("expected an empty history error, got %v", err)
+	}
+	if err := fresh.removeTodo(1); err == nil || fresh.undo() == nil {
+		t.Error("expected a failed command not to be recorded")
+	}
+}
+
+func TestMenu_ResetConfirmation(t *testing.T) {
+	input := strings.Join([]string{
+		"5", "n",
+		"5", "y",
+		"9",
+		"10",
+		"10",
+		"0",
+	}, "\n") + "\n"
+
+	l := newTestTodoList(t, "")
+	l.addTodos(newTodo("title", ""))
+	var out bytes.Buffer
+	m := newMenu(l.store, strings.NewReader(input), &out)
+	m.start()
+
+	prompt := menuPrompt("default")
+	want := prompt + "Delete all todos? (y/N): Reset cancelled.\n" +
+		prompt + "Delete all todos? (y/N): " +
+		prompt +
+		prompt +
+		prompt + "Sorry, there is nothing to redo. Please try again.\n" +
+		prompt
+	if out.String() != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if len(l.todos) != 0 {
+		t.Error("expected the reset to be redone")
+	}
+}
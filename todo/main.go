@@ -1,7 +1,45 @@
 package main
 
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+)
+
 func main() {
-	l := newTodoList()
-	m := newMenu(l)
+	path := flag.String("file", "todos.json", "file the todos are stored in, a SQLite database if it ends in .db, .sqlite or .sqlite3 and JSON otherwise")
+	listName := flag.String("list", "", "list the commands work on, the current list if empty")
+	remind := flag.String("remind", defaultReminders, "how long before the due time the menu shows reminders, empty for none")
+	flag.Parse()
+
+	s, err := openStore(*path)
+	if err != nil {
+		log.Fatalf("error loading todos: %v", err)
+	}
+	if flag.NArg() > 0 {
+		l := s.currentList()
+		if *listName != "" {
+			if l, err = s.getList(*listName); err != nil {
+				log.Fatalf("error selecting list: %v", err)
+			}
+		}
+		code := runCommand(l, flag.Args())
+		s.close()
+		os.Exit(code)
+	}
+	offsets, err := parseReminders(*remind)
+	if err != nil {
+		log.Fatalf("error parsing -remind: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(offsets) > 0 {
+		go newScheduler(s, offsets, newBannerNotifier(os.Stdout)).run(ctx)
+	}
+	m := newMenu(s, os.Stdin, os.Stdout)
 	m.start()
+	cancel()
+	if err := s.close(); err != nil {
+		log.Fatalf("error closing todos: %v", err)
+	}
 }
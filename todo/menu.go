@@ -2,37 +2,61 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"io"
+	"strings"
+	"time"
 )
 
 type menu struct {
-	todoList  *todoList
+	store     *store
+	scanner   *bufio.Scanner
+	out       io.Writer
 	keepGoing bool
 }
 
-func newMenu(list *todoList) *menu {
+func newMenu(s *store, in io.Reader, out io.Writer) *menu {
 	return &menu{
-		todoList:  list,
+		store:     s,
+		scanner:   bufio.NewScanner(in),
+		out:       out,
 		keepGoing: true,
 	}
 }
 
 const displayText = `
 Welcome to Todo List App
+Current list: %s
 Select an option:
   1. Display Todo List
   2. Add Todo
   3. Delete Todo
   4. Complete Todo
   5. Reset
+  6. Filter Todo List
+  7. Edit Todo
+  8. Un-complete Todo
+  9. Undo
+  10. Redo
+  11. Show Lists
+  12. Create List
+  13. Switch List
+  14. Rename List
+  15. Delete List
+  16. Move Todo to List
+  17. Add Subtask
+  18. Search Todos
   0. Exit
 `
 
 func (m *menu) display() {
-	fmt.Print(displayText)
+	fmt.Fprintf(m.out, displayText, m.list().name)
+}
+
+// list returns the list the options work on.
+func (m *menu) list() *todoList {
+	return m.store.currentList()
 }
 
 func (m *menu) start() {
@@ -45,7 +69,8 @@ func (m *menu) start() {
 func (m *menu) processOption() {
 	option, err := m.getInput("Select an option: ")
 	if err != nil {
-		log.Fatalf("error scanning option: %v", err)
+		m.stop(err)
+		return
 	}
 	m.useOption(option)
 }
@@ -67,68 +92,529 @@ func (m *menu) useOption(option string) {
 	case "5":
 		m.resetOption()
 		break
+	case "6":
+		m.filterTodoListOption()
+		break
+	case "7":
+		m.editTodoOption()
+		break
+	case "8":
+		m.uncompleteTodoOption()
+		break
+	case "9":
+		m.undoOption()
+		break
+	case "10":
+		m.redoOption()
+		break
+	case "11":
+		m.showListsOption()
+		break
+	case "12":
+		m.createListOption()
+		break
+	case "13":
+		m.switchListOption()
+		break
+	case "14":
+		m.renameListOption()
+		break
+	case "15":
+		m.deleteListOption()
+		break
+	case "16":
+		m.moveTodoOption()
+		break
+	case "17":
+		m.addSubtaskOption()
+		break
+	case "18":
+		m.searchOption()
+		break
 	case "0":
 		m.exitOption()
 		break
+	default:
+		m.showError(invalidOptionError(option))
 	}
 }
 
 func (m *menu) displayTodoListOption() {
-	m.todoList.getTodos()
+	m.list().getTodos(m.out)
 }
 
 func (m *menu) addTodoOption() {
+	m.addTodo(0)
+}
+
+func (m *menu) addSubtaskOption() {
+	strId, err := m.getInput("Parent ID: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	m.addTodo(id)
+}
+
+// addTodo prompts for a todo and adds it, as a subtask if parentID is
+// set.
+func (m *menu) addTodo(parentID int) {
 	title, err := m.getInput("Title: ")
 	if err != nil {
-		log.Fatalf("error getting Title: %v", err)
+		m.stop(err)
+		return
 	}
 	desc, err := m.getInput("Description: ")
 	if err != nil {
-		log.Fatalf("error getting Title: %v", err)
+		m.stop(err)
+		return
+	}
+	strDue, err := m.getInput("Due (YYYY-MM-DD [HH:MM], optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	due, err := parseDue(strDue)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	strRepeat, err := m.getInput("Repeat (daily/weekly/monthly [day]/every N days, optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	repeat, err := parseRecurrence(strRepeat, due)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	strPriority, err := m.getInput("Priority (low/medium/high, optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	p, err := parsePriority(strPriority)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	strTags, err := m.getInput("Tags (comma separated, optional): ")
+	if err != nil {
+		m.stop(err)
+		return
 	}
 	t := newTodo(title, desc)
-	m.todoList.addTodos(t)
+	t.due = due
+	t.recurrence = repeat
+	t.priority = p
+	t.tags = parseTags(strTags)
+	t.parentID = parentID
+	if err := m.list().addTodos(t); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) filterTodoListOption() {
+	tag, err := m.getInput("Tag (optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	strStatus, err := m.getInput("Status (all/open/done, optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	st, err := parseStatus(strStatus)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	strOverdue, err := m.getInput("Overdue only (y/N): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	strSort, err := m.getInput("Sort by (due/priority, optional): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	sortBy, err := parseSortKey(strSort)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	opts := listOptions{
+		tag:     strings.TrimSpace(tag),
+		status:  st,
+		overdue: strings.EqualFold(strings.TrimSpace(strOverdue), "y"),
+		sortBy:  sortBy,
+	}
+	printTodos(m.out, m.list().listTodos(opts, time.Now()))
+}
+
+func (m *menu) searchOption() {
+	query, err := m.getInput("Search: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	todos, err := m.list().search(query)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	printSearchResults(m.out, query, todos)
 }
 
 func (m *menu) deleteTodoOption() {
 	strId, err := m.getInput("ID: ")
 	if err != nil {
-		log.Fatalf("error scanning id: %v", err)
+		m.stop(err)
+		return
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		m.showError(err)
+		return
 	}
-	id, err := strconv.Atoi(strId)
+	t, err := m.list().getTodo(id)
 	if err != nil {
-		log.Fatalf("error convert strId to id: %v", err)
+		m.showError(err)
+		return
+	}
+	policy := subtasksRefuse
+	if t.progress.total > 0 {
+		answer, err := m.getInput(fmt.Sprintf("Todo %d has %d subtasks, delete or keep them? (delete/keep): ", id, t.progress.total))
+		if err != nil {
+			m.stop(err)
+			return
+		}
+		if policy, err = parseSubtaskPolicy(strings.TrimSpace(answer)); err != nil {
+			m.showError(err)
+			return
+		}
+	}
+	if err := m.list().removeTodoWith(id, policy); err != nil {
+		m.showError(err)
 	}
-	m.todoList.removeTodo(id)
 }
 
 func (m *menu) completeTodoOption() {
 	strId, err := m.getInput("ID: ")
 	if err != nil {
-		log.Fatalf("error scanning id: %v", err)
+		m.stop(err)
+		return
 	}
-	id, err := strconv.Atoi(strId)
+	id, err := parseID(strId)
 	if err != nil {
-		log.Fatalf("error convert strId to id: %v", err)
+		m.showError(err)
+		return
+	}
+	t, err := m.list().getTodo(id)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	withSubtasks := false
+	if open := t.progress.total - t.progress.done; open > 0 {
+		answer, err := m.getInput(fmt.Sprintf("Complete its %d open subtasks too? (y/N): ", open))
+		if err != nil {
+			m.stop(err)
+			return
+		}
+		withSubtasks = strings.EqualFold(strings.TrimSpace(answer), "y")
+	}
+	if err := m.list().completeTodoWith(id, withSubtasks); err != nil {
+		m.showError(err)
 	}
-	m.todoList.completeTodo(id)
 }
 
-func (m *menu) getInput(title string) (string, error) {
-	fmt.Print(title)
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	err := scanner.Err()
+func (m *menu) uncompleteTodoOption() {
+	strId, err := m.getInput("ID: ")
 	if err != nil {
-		return "", fmt.Errorf("error reading input %s: %v", title, err)
+		m.stop(err)
+		return
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	if err := m.list().uncompleteTodo(id); err != nil {
+		m.showError(err)
 	}
-	return scanner.Text(), nil
+}
+
+func (m *menu) editTodoOption() {
+	strId, err := m.getInput("ID: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	t, err := m.list().getTodo(id)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+
+	fmt.Fprintln(m.out, "Press enter to keep a value, - to clear it.")
+	var e todoEdit
+	title, keep, err := m.getEdit("Title", t.title)
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		e.title = &title
+	}
+	desc, keep, err := m.getEdit("Description", t.description)
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		e.description = &desc
+	}
+	currentDue := ""
+	if !t.due.IsZero() {
+		currentDue = formatDue(t.due)
+	}
+	strDue, keep, err := m.getEdit("Due", currentDue)
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		due, err := parseDue(strDue)
+		if err != nil {
+			m.showError(err)
+			return
+		}
+		e.due = &due
+	}
+	strRepeat, keep, err := m.getEdit("Repeat", t.recurrence.String())
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		due := t.due
+		if e.due != nil {
+			due = *e.due
+		}
+		repeat, err := parseRecurrence(strRepeat, due)
+		if err != nil {
+			m.showError(err)
+			return
+		}
+		e.recurrence = &repeat
+	}
+	strPriority, keep, err := m.getEdit("Priority", t.priority.String())
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		p, err := parsePriority(strPriority)
+		if err != nil {
+			m.showError(err)
+			return
+		}
+		e.priority = &p
+	}
+	strTags, keep, err := m.getEdit("Tags", strings.Join(t.tags, ", "))
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !keep {
+		tags := parseTags(strTags)
+		e.tags = &tags
+	}
+
+	if err := m.list().editTodo(id, e); err != nil {
+		m.showError(err)
+	}
+}
+
+// getEdit prompts for a new value of a field showing its current one.
+// An empty answer keeps the field and "-" clears it.
+func (m *menu) getEdit(label, current string) (value string, keep bool, err error) {
+	input, err := m.getInput(fmt.Sprintf("%s [%s]: ", label, current))
+	if err != nil {
+		return "", false, err
+	}
+	switch input {
+	case "":
+		return "", true, nil
+	case "-":
+		return "", false, nil
+	default:
+		return input, false, nil
+	}
+}
+
+// getInput prompts for and reads a single line. It returns io.EOF once
+// the input is exhausted.
+func (m *menu) getInput(title string) (string, error) {
+	fmt.Fprint(m.out, title)
+	if !m.scanner.Scan() {
+		if err := m.scanner.Err(); err != nil {
+			return "", fmt.Errorf("error reading input %s: %v", title, err)
+		}
+		return "", io.EOF
+	}
+	return m.scanner.Text(), nil
+}
+
+// showError tells the user what went wrong and lets the menu carry on.
+func (m *menu) showError(err error) {
+	if errorKind(err) != 0 {
+		fmt.Fprintf(m.out, "Sorry, %v. Please try again.\n", err)
+		return
+	}
+	fmt.Fprintf(m.out, "Something went wrong: %v\n", err)
+}
+
+// stop ends the menu loop after the input failed or reached EOF.
+func (m *menu) stop(err error) {
+	if !errors.Is(err, io.EOF) {
+		fmt.Fprintln(m.out, err)
+	}
+	m.keepGoing = false
 }
 
 func (m *menu) resetOption() {
-	m.todoList.reset()
+	answer, err := m.getInput("Delete all todos? (y/N): ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		fmt.Fprintln(m.out, "Reset cancelled.")
+		return
+	}
+	if err := m.list().reset(); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) undoOption() {
+	if err := m.list().undo(); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) redoOption() {
+	if err := m.list().redo(); err != nil {
+		m.showError(err)
+	}
 }
 
 func (m *menu) exitOption() {
 	m.keepGoing = false
 }
+
+func (m *menu) showListsOption() {
+	current := m.list().name
+	fmt.Fprintln(m.out, "Lists")
+	for _, name := range m.store.listNames() {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Fprintf(m.out, "%s %s\n", marker, name)
+	}
+}
+
+func (m *menu) createListOption() {
+	name, err := m.getInput("Name: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if err := m.store.createList(name); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) switchListOption() {
+	name, err := m.getInput("Name: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if err := m.store.switchList(name); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) renameListOption() {
+	oldName, err := m.getInput("Name: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	newName, err := m.getInput("New name: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if err := m.store.renameList(oldName, newName); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) deleteListOption() {
+	name, err := m.getInput("Name: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	answer, err := m.getInput(fmt.Sprintf("Delete list %q and all its todos? (y/N): ", name))
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		fmt.Fprintln(m.out, "Delete cancelled.")
+		return
+	}
+	if err := m.store.deleteList(name); err != nil {
+		m.showError(err)
+	}
+}
+
+func (m *menu) moveTodoOption() {
+	strId, err := m.getInput("ID: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	id, err := parseID(strId)
+	if err != nil {
+		m.showError(err)
+		return
+	}
+	name, err := m.getInput("To list: ")
+	if err != nil {
+		m.stop(err)
+		return
+	}
+	if err := m.store.moveTodo(id, name); err != nil {
+		m.showError(err)
+	}
+}
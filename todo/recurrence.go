@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type recurrenceKind uint8
+
+const (
+	recurNone recurrenceKind = iota
+	recurDaily
+	recurWeekly
+	recurMonthly
+	recurEveryNDays
+)
+
+// recurrence is the rule a recurring todo's next due date follows.
+type recurrence struct {
+	kind recurrenceKind
+	// day is the day of the month of a monthly rule.
+	day int
+	// n is the number of days of an every N days rule.
+	n int
+}
+
+const recurrenceHelp = "use daily, weekly, monthly, monthly <day> or every <n> days"
+
+// parseRecurrence parses a rule written as daily, weekly, monthly <day> or
+// every <n> days. Monthly without a day repeats on the day of due. An
+// empty string is no recurrence.
+func parseRecurrence(s string, due time.Time) (recurrence, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	invalid := invalidInputError(fmt.Sprintf("%q is not a valid recurrence, %s", s, recurrenceHelp))
+	switch {
+	case len(fields) == 0:
+		return recurrence{}, nil
+	case len(fields) == 1 && fields[0] == "daily":
+		return recurrence{kind: recurDaily}, nil
+	case len(fields) == 1 && fields[0] == "weekly":
+		return recurrence{kind: recurWeekly}, nil
+	case fields[0] == "monthly" && len(fields) <= 2:
+		day := due.Day()
+		if len(fields) == 2 {
+			var err error
+			if day, err = strconv.Atoi(fields[1]); err != nil || day < 1 || day > 31 {
+				return recurrence{}, invalid
+			}
+		}
+		return recurrence{kind: recurMonthly, day: day}, nil
+	case fields[0] == "every" && len(fields) == 3 && (fields[2] == "days" || fields[2] == "day"):
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return recurrence{}, invalid
+		}
+		return recurrence{kind: recurEveryNDays, n: n}, nil
+	default:
+		return recurrence{}, invalid
+	}
+}
+
+func (r recurrence) String() string {
+	switch r.kind {
+	case recurDaily:
+		return "daily"
+	case recurWeekly:
+		return "weekly"
+	case recurMonthly:
+		return fmt.Sprintf("monthly %d", r.day)
+	case recurEveryNDays:
+		if r.n == 1 {
+			return "every 1 day"
+		}
+		return fmt.Sprintf("every %d days", r.n)
+	default:
+		return ""
+	}
+}
+
+// next returns the due date of the occurrence after the one due at due.
+// Monthly rules fall on the last day of months too short for their day,
+// so monthly 31 goes Jan 31, Feb 28, Mar 31.
+func (r recurrence) next(due time.Time) time.Time {
+	switch r.kind {
+	case recurDaily:
+		return due.AddDate(0, 0, 1)
+	case recurWeekly:
+		return due.AddDate(0, 0, 7)
+	case recurEveryNDays:
+		return due.AddDate(0, 0, r.n)
+	case recurMonthly:
+		// AddDate normalizes Jan 31 + 1 month to Mar 2, so build the date
+		// from the first of the next month instead
+		first := time.Date(due.Year(), due.Month()+1, 1, due.Hour(), due.Minute(), due.Second(), due.Nanosecond(), due.Location())
+		day := min(r.day, daysIn(first.Year(), first.Month()))
+		return first.AddDate(0, 0, day-1)
+	default:
+		return due
+	}
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestRecurrence_Next(t *testing.T) {
+	tests := []struct {
+		rule string
+		due  time.Time
+		want []time.Time
+	}{
+		{"daily", date(2024, time.December, 31), []time.Time{date(2025, time.January, 1), date(2025, time.January, 2)}},
+		{"weekly", date(2024, time.February, 26), []time.Time{date(2024, time.March, 4), date(2024, time.March, 11)}},
+		{"every 10 days", date(2024, time.February, 25), []time.Time{date(2024, time.March, 6), date(2024, time.March, 16)}},
+		{"monthly", date(2024, time.January, 31), []time.Time{date(2024, time.February, 29), date(2024, time.March, 31), date(2024, time.April, 30)}},
+		{"monthly", date(2023, time.January, 31), []time.Time{date(2023, time.February, 28), date(2023, time.March, 31)}},
+		{"monthly 30", date(2024, time.January, 15), []time.Time{date(2024, time.February, 29), date(2024, time.March, 30)}},
+		{"monthly 15", date(2024, time.December, 15), []time.Time{date(2025, time.January, 15)}},
+	}
+	for _, tt := range tests {
+		r, err := parseRecurrence(tt.rule, tt.due)
+		if err != nil {
+			t.Fatalf("parseRecurrence(%q): %v", tt.rule, err)
+		}
+		due := tt.due
+		for _, want := range tt.want {
+			due = r.next(due)
+			if !due.Equal(want) {
+				t.Errorf("%s from %s: expected %s, got %s", tt.rule, tt.due.Format(dateLayout), want.Format(dateLayout), due.Format(dateLayout))
+			}
+		}
+	}
+
+	r, _ := parseRecurrence("daily", time.Time{})
+	due := time.Date(2024, time.March, 1, 9, 30, 0, 0, time.Local)
+	if next := r.next(due); !next.Equal(due.AddDate(0, 0, 1)) {
+		t.Errorf("expected the time of day to be kept, got %v", next)
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	due := date(2024, time.January, 31)
+	valid := map[string]string{
+		"":              "",
+		"Daily":         "daily",
+		"weekly":        "weekly",
+		"monthly":       "monthly 31",
+		"monthly 5":     "monthly 5",
+		"every 3 days":  "every 3 days",
+		"every 1 day":   "every 1 day",
+		" every 2 days": "every 2 days",
+	}
+	for s, want := range valid {
+		r, err := parseRecurrence(s, due)
+		if err != nil || r.String() != want {
+			t.Errorf("parseRecurrence(%q) = %q, %v, want %q", s, r, err, want)
+		}
+	}
+	for _, s := range []string{"yearly", "monthly 32", "monthly 0", "every 0 days", "every days", "every 2 weeks"} {
+		if _, err := parseRecurrence(s, due); errorKind(err) != todoErrorKindInvalidInput {
+			t.Errorf("parseRecurrence(%q): expected an invalid input error, got %v", s, err)
+		}
+	}
+}
+
+func TestTodoList_CompleteRecurring(t *testing.T) {
+	td := newTodo("rotate credentials", "")
+	td.due = date(2024, time.January, 31)
+	td.recurrence, _ = parseRecurrence("monthly", td.due)
+	td.tags = []string{"ops"}
+	list := newTestTodoList(t, "")
+	list.addTodos(td)
+
+	if err := list.completeTodo(td.id); err != nil {
+		t.Fatalf("error completing todo: %v", err)
+	}
+	if len(list.todos) != 2 {
+		t.Fatalf("expected the next occurrence to be added, got %d todos", len(list.todos))
+	}
+	first, next := list.todos[0], list.todos[1]
+	if !first.completed || first.recurrence.kind != recurNone {
+		t.Errorf("expected the completed todo to hand on its recurrence, got %+v", *first)
+	}
+	if next.completed || next.id != 2 || !next.due.Equal(date(2024, time.February, 29)) || next.recurrence.String() != "monthly 31" || next.tags[0] != "ops" {
+		t.Errorf("unexpected next occurrence %+v", *next)
+	}
+
+	// completing again doesn't spawn a second occurrence
+	list.uncompleteTodo(first.id)
+	list.completeTodo(first.id)
+	if len(list.todos) != 2 {
+		t.Errorf("expected no new occurrence, got %d todos", len(list.todos))
+	}
+
+	list.completeTodo(next.id)
+	if third := list.todos[2]; !third.due.Equal(date(2024, time.March, 31)) {
+		t.Errorf("expected the day of month to be kept after a short month, got %s", formatDue(third.due))
+	}
+
+	list.undo()
+	list.undo()
+	list.undo()
+	if len(list.todos) != 2 {
+		t.Fatalf("expected undo to remove the spawned occurrence, got %d todos", len(list.todos))
+	}
+	list.undo()
+	if len(list.todos) != 1 || first.completed || first.recurrence.kind != recurMonthly {
+		t.Errorf("expected undo to restore the recurring todo, got %+v", *first)
+	}
+	list.redo()
+	if len(list.todos) != 2 || list.todos[1].id != 2 {
+		t.Error("expected redo to bring back the occurrence under the same ID")
+	}
+}
+
+func TestTodo_RecurringNeedsDue(t *testing.T) {
+	td := newTodo("title", "")
+	td.recurrence = recurrence{kind: recurDaily}
+	list := newTestTodoList(t, "")
+	if err := list.addTodos(td); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultReminders is how long before the due time reminders go off
+// unless told otherwise.
+const defaultReminders = "1h,15m"
+
+// reminderInterval is how often the scheduler looks for due reminders.
+const reminderInterval = 30 * time.Second
+
+// parseReminders parses a comma separated list of durations such as
+// "1d,2h,15m". An empty string turns reminders off.
+func parseReminders(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		d, err := parseOffset(field)
+		if err != nil || d < 0 {
+			return nil, invalidInputError(fmt.Sprintf("%q is not a valid reminder, use durations like 1d, 2h or 15m", field))
+		}
+		if !slices.Contains(offsets, d) {
+			offsets = append(offsets, d)
+		}
+	}
+	return offsets, nil
+}
+
+// parseOffset is time.ParseDuration that also accepts whole days.
+func parseOffset(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+// reminderKey identifies a reminder that went off. The due date is part
+// of it, so moving the due date or the next occurrence of a recurring
+// todo gets reminders of its own.
+type reminderKey struct {
+	todoID int
+	due    int64
+	before time.Duration
+}
+
+type reminderRecord struct {
+	TodoID int       `json:"todoId"`
+	Due    time.Time `json:"due"`
+	Before string    `json:"before"`
+}
+
+func (k reminderKey) record() reminderRecord {
+	return reminderRecord{
+		TodoID: k.todoID,
+		Due:    time.Unix(k.due, 0).UTC(),
+		Before: k.before.String(),
+	}
+}
+
+func newReminderKey(r reminderRecord) (reminderKey, error) {
+	before, err := time.ParseDuration(r.Before)
+	if err != nil {
+		return reminderKey{}, err
+	}
+	return reminderKey{todoID: r.TodoID, due: r.Due.Unix(), before: before}, nil
+}
+
+// reminder is a todo due soon.
+type reminder struct {
+	list   string
+	todo   *todo
+	before time.Duration
+}
+
+// notifier delivers reminders.
+type notifier interface {
+	Notify(ctx context.Context, r reminder) error
+}
+
+// scheduler sends the reminders of the todos in a store at the set times
+// before they are due.
+type scheduler struct {
+	store    *store
+	offsets  []time.Duration
+	notifier notifier
+	interval time.Duration
+}
+
+func newScheduler(s *store, offsets []time.Duration, n notifier) *scheduler {
+	return &scheduler{
+		store:    s,
+		offsets:  offsets,
+		notifier: n,
+		interval: reminderInterval,
+	}
+}
+
+// run sends reminders until ctx is cancelled.
+func (sc *scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		sc.check(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check sends the reminders due at now.
+func (sc *scheduler) check(ctx context.Context, now time.Time) {
+	reminders, err := sc.store.dueReminders(sc.offsets, now)
+	if err != nil {
+		log.Printf("error saving reminders: %v", err)
+		return
+	}
+	for _, r := range reminders {
+		if err := sc.notifier.Notify(ctx, r); err != nil {
+			log.Printf("error sending reminder for todo %d: %v", r.todo.id, err)
+		}
+	}
+}
+
+// dueReminders returns the reminders due at now and records them as
+// sent. They are saved before they are sent, so a restart or another
+// process sharing the store never sends a reminder twice. A reminder
+// missed while the app was not running is still sent if the todo isn't
+// due yet and no later reminder is due.
+func (s *store) dueReminders(offsets []time.Duration, now time.Time) ([]reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// most checks find nothing due, those don't lock the TodoStore
+	s.sync()
+	if keys, _ := s.pendingReminders(offsets, now); len(keys) == 0 {
+		return nil, nil
+	}
+	var reminders []reminder
+	err := s.update(func() error {
+		var keys []reminderKey
+		keys, reminders = s.pendingReminders(offsets, now)
+		for _, key := range keys {
+			s.reminded[key] = true
+			s.record(change{kind: reminderSent, reminder: key.record()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// pendingReminders returns the keys of the reminders due at now that
+// didn't go off yet, and the reminders among them still worth sending.
+// Only the latest reminder due for a todo is sent, the earlier ones a
+// late check finds due as well are passed over. s.mu must be held.
+func (s *store) pendingReminders(offsets []time.Duration, now time.Time) ([]reminderKey, []reminder) {
+	var keys []reminderKey
+	var reminders []reminder
+	for _, l := range s.lists {
+		for _, t := range l.todos {
+			if t.completed || t.due.IsZero() {
+				continue
+			}
+			deadline := t.deadline()
+			latest := time.Duration(-1)
+			for _, before := range offsets {
+				if now.Before(deadline.Add(-before)) {
+					continue
+				}
+				if key := (reminderKey{todoID: t.id, due: t.due.Unix(), before: before}); !s.reminded[key] {
+					keys = append(keys, key)
+				}
+				if latest < 0 || before < latest {
+					latest = before
+				}
+			}
+			key := reminderKey{todoID: t.id, due: t.due.Unix(), before: latest}
+			if latest >= 0 && !s.reminded[key] && now.Before(deadline) {
+				reminders = append(reminders, reminder{list: l.name, todo: l.view(t), before: latest})
+			}
+		}
+	}
+	return keys, reminders
+}
+
+// bannerNotifier prints reminders for someone at the terminal.
+type bannerNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newBannerNotifier(w io.Writer) *bannerNotifier {
+	return &bannerNotifier{
+		w: w,
+	}
+}
+
+func (b *bannerNotifier) Notify(ctx context.Context, r reminder) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := fmt.Fprintf(b.w, "\n*** Reminder: %q (ID %d, list %s) is due %s ***\n",
+		r.todo.title, r.todo.id, r.list, formatDue(r.todo.due))
+	return err
+}
+
+// webhookEvent is the body of a reminder webhook.
+type webhookEvent struct {
+	Event  string     `json:"event"`
+	List   string     `json:"list"`
+	Before string     `json:"before"`
+	Todo   todoRecord `json:"todo"`
+}
+
+// webhookNotifier POSTs reminders as JSON to a URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string) *webhookNotifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (wh *webhookNotifier) Notify(ctx context.Context, r reminder) error {
+	body, err := json.Marshal(webhookEvent{
+		Event:  "reminder",
+		List:   r.list,
+		Before: r.before.String(),
+		Todo:   r.todo.record(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
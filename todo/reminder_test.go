@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordNotifier struct {
+	sent []string
+}
+
+func (n *recordNotifier) Notify(ctx context.Context, r reminder) error {
+	n.sent = append(n.sent, r.todo.title+" "+r.before.String())
+	return nil
+}
+
+func TestParseReminders(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []time.Duration
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"1d, 2h,15m,2h", []time.Duration{24 * time.Hour, 2 * time.Hour, 15 * time.Minute}, false},
+		{"soon", nil, true},
+		{"-1h", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseReminders(tt.s)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseReminders(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	for _, file := range []string{"todos.json", "todos.db"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			s := newClosingTestStore(t, path)
+			l := s.currentList()
+			meeting := newTodo("meeting", "")
+			meeting.due = time.Date(2024, time.March, 1, 10, 0, 0, 0, time.Local)
+			report := newTodo("report", "")
+			report.due = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)
+			l.addTodos(meeting, report, newTodo("someday", ""))
+
+			n := &recordNotifier{}
+			sc := newScheduler(s, []time.Duration{time.Hour, 15 * time.Minute}, n)
+			ctx := context.Background()
+			at := func(hour, min int) time.Time {
+				return time.Date(2024, time.March, 1, hour, min, 0, 0, time.Local)
+			}
+
+			sc.check(ctx, at(8, 0))
+			sc.check(ctx, at(9, 0))
+			// a todo added inside its reminder window only gets the latest
+			call := newTodo("call", "")
+			call.due = at(9, 40)
+			l.addTodos(call)
+			sc.check(ctx, at(9, 30))
+			// a date-only todo is due by the end of the day
+			sc.check(ctx, at(23, 0))
+			want := []string{"meeting 1h0m0s", "call 15m0s", "report 1h0m0s"}
+			if !slices.Equal(n.sent, want) {
+				t.Fatalf("expected %q, got %q", want, n.sent)
+			}
+
+			// after a restart the reminders that went off stay quiet, the
+			// one missed while the app was down still goes off
+			restarted := newClosingTestStore(t, path)
+			n = &recordNotifier{}
+			sc = newScheduler(restarted, []time.Duration{time.Hour, 15 * time.Minute}, n)
+			sc.check(ctx, at(23, 50))
+			sc.check(ctx, at(23, 55))
+			if want := []string{"report 15m0s"}; !slices.Equal(n.sent, want) {
+				t.Fatalf("expected %q after a restart, got %q", want, n.sent)
+			}
+
+			// moving the due date brings the reminders back
+			l = restarted.currentList()
+			due := time.Date(2024, time.March, 2, 10, 0, 0, 0, time.Local)
+			l.editTodo(1, todoEdit{due: &due})
+			l.completeTodo(2)
+			n.sent = nil
+			// found late, only the latest reminder due goes off
+			sc.check(ctx, time.Date(2024, time.March, 2, 9, 50, 0, 0, time.Local))
+			sc.check(ctx, time.Date(2024, time.March, 2, 9, 55, 0, 0, time.Local))
+			if want := []string{"meeting 15m0s"}; !slices.Equal(n.sent, want) {
+				t.Errorf("expected %q, got %q", want, n.sent)
+			}
+		})
+	}
+}
+
+func TestBannerNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	td := newTodo("meeting", "")
+	td.id = 3
+	td.due = time.Date(2024, time.March, 1, 10, 0, 0, 0, time.Local)
+	newBannerNotifier(&buf).Notify(context.Background(), reminder{list: "work", todo: td, before: time.Hour})
+	want := "\n*** Reminder: \"meeting\" (ID 3, list work) is due 2024-03-01 10:00 ***\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got webhookEvent
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	td := newTodo("meeting", "")
+	td.id = 3
+	td.due = time.Date(2024, time.March, 1, 10, 0, 0, 0, time.Local)
+	r := reminder{list: "work", todo: td, before: 15 * time.Minute}
+	n := newWebhookNotifier(srv.URL)
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("error sending webhook: %v", err)
+	}
+	if got.Event != "reminder" || got.List != "work" || got.Before != "15m0s" || got.Todo.ID != 3 {
+		t.Errorf("unexpected event %+v", got)
+	}
+
+	status = http.StatusBadGateway
+	if err := n.Notify(context.Background(), r); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected an error for a failed webhook, got %v", err)
+	}
+}
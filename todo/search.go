@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Where a search matched a todo, best first.
+const (
+	matchTitle = iota
+	matchTags
+	matchDescription
+	noMatch
+)
+
+// search returns copies of the todos whose title, description or tags
+// contain the query, ignoring case. Title matches come first, then tag
+// matches and description matches; ties keep the list order.
+func (l *todoList) search(query string) ([]*todo, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, invalidInputError("search query must not be empty")
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if err := l.store.refresh(); err != nil {
+		return nil, err
+	}
+	var todos []*todo
+	var ranks []int
+	for _, t := range l.todos {
+		if r := rank(t, query); r != noMatch {
+			todos = append(todos, l.view(t))
+			ranks = append(ranks, r)
+		}
+	}
+	sort.Stable(byRank{todos, ranks})
+	return todos, nil
+}
+
+func rank(t *todo, query string) int {
+	switch {
+	case containsFold(t.title, query):
+		return matchTitle
+	case slices.ContainsFunc(t.tags, func(tag string) bool { return containsFold(tag, query) }):
+		return matchTags
+	case containsFold(t.description, query):
+		return matchDescription
+	default:
+		return noMatch
+	}
+}
+
+type byRank struct {
+	todos []*todo
+	ranks []int
+}
+
+func (b byRank) Len() int           { return len(b.todos) }
+func (b byRank) Less(i, j int) bool { return b.ranks[i] < b.ranks[j] }
+func (b byRank) Swap(i, j int) {
+	b.todos[i], b.todos[j] = b.todos[j], b.todos[i]
+	b.ranks[i], b.ranks[j] = b.ranks[j], b.ranks[i]
+}
+
+// indexFold returns the start and end of the first match of substr in s
+// under Unicode case folding, or -1, -1. Unlike lowering both strings
+// first, the bounds are valid in s even when case changes the length of a
+// rune.
+func indexFold(s, substr string) (start, end int) {
+	n := utf8.RuneCountInString(substr)
+	for i := range s {
+		j := i
+		for k := 0; k < n && j < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[j:])
+			j += size
+		}
+		if strings.EqualFold(s[i:j], substr) {
+			return i, j
+		}
+	}
+	return -1, -1
+}
+
+func containsFold(s, substr string) bool {
+	i, _ := indexFold(s, substr)
+	return i >= 0
+}
+
+// highlight marks every match of query in s with brackets.
+func highlight(s, query string) string {
+	var b strings.Builder
+	for {
+		i, j := indexFold(s, query)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		fmt.Fprintf(&b, "%s[%s]", s[:i], s[i:j])
+		s = s[j:]
+	}
+}
+
+// printSearchResults prints the todos found for query with the matches
+// highlighted.
+func printSearchResults(w io.Writer, query string, todos []*todo) {
+	query = strings.TrimSpace(query)
+	fmt.Fprintf(w, "Search results for %q\n", query)
+	if len(todos) == 0 {
+		fmt.Fprintln(w, "(no matches)")
+	}
+	for _, t := range todos {
+		h := t.clone()
+		h.title = highlight(t.title, query)
+		h.description = highlight(t.description, query)
+		for i, tag := range h.tags {
+			h.tags[i] = highlight(tag, query)
+		}
+		h.print(w)
+	}
+}
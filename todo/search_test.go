@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTodoList_Search(t *testing.T) {
+	list := newTestTodoList(t, "")
+	groceries := newTodo("Buy groceries", "milk and bread")
+	groceries.tags = []string{"home"}
+	report := newTodo("Write report", "for the MILK producers")
+	dairy := newTodo("Call vet", "")
+	dairy.tags = []string{"dairy-milk"}
+	list.addTodos(groceries, report, dairy, newTodo("Milk the cow", ""))
+
+	todos, err := list.search(" milk ")
+	if err != nil {
+		t.Fatalf("error searching: %v", err)
+	}
+	var got []string
+	for _, td := range todos {
+		got = append(got, td.title)
+	}
+	if want := "Milk the cow,Call vet,Buy groceries,Write report"; strings.Join(got, ",") != want {
+		t.Errorf("expected %q, got %q", want, strings.Join(got, ","))
+	}
+
+	if todos, _ := list.search("nothing"); len(todos) != 0 {
+		t.Errorf("expected no matches, got %d", len(todos))
+	}
+	if _, err := list.search("  "); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an empty query to be refused, got %v", err)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		s, query, want string
+	}{
+		{"Milk and milk", "milk", "[Milk] and [milk]"},
+		{"no match", "milk", "no match"},
+		{"", "milk", ""},
+		{"Straße", "SSE", "Straße"},
+		{"ÉCOLE école", "école", "[ÉCOLE] [école]"},
+		{"KELVIN", "Kelvin", "[KELVIN]"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.s, tt.query); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.s, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMenu_Search(t *testing.T) {
+	list := newTestTodoList(t, "")
+	td := newTodo("Buy groceries", "milk")
+	td.tags = []string{"shopping"}
+	list.addTodos(td)
+
+	var out bytes.Buffer
+	newMenu(list.store, strings.NewReader("18\nMILK\n18\n\n0\n"), &out).start()
+
+	want := `Search: Search results for "MILK"
+
+ID:          1
+Title:       Buy groceries
+Description: [milk]
+Completed:   false
+Tags:        shopping
+`
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("expected output to contain %q, got %q", want, out.String())
+	}
+	if !strings.Contains(out.String(), "Search: Sorry, search query must not be empty. Please try again.") {
+		t.Errorf("expected an empty query to be refused, got %q", out.String())
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// server exposes a todoList as a JSON REST API and a browser UI.
+type server struct {
+	store    *store
+	listName string
+}
+
+func newServer(list *todoList) *server {
+	return &server{
+		store:    list.store,
+		listName: list.name,
+	}
+}
+
+// todoList returns the list served. It is looked up by name on every
+// request, as another process may rename or delete it; requests then fail
+// with not found instead of working on a list that is no longer stored.
+func (s *server) todoList() (*todoList, error) {
+	return s.store.getList(s.listName)
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /todos", s.listTodos)
+	mux.HandleFunc("POST /todos", requireJSON(s.createTodo))
+	mux.HandleFunc("GET /todos/{id}", s.getTodo)
+	mux.HandleFunc("PATCH /todos/{id}", requireJSON(s.updateTodo))
+	mux.HandleFunc("DELETE /todos/{id}", requireJSON(s.deleteTodo))
+	mux.HandleFunc("POST /todos/{id}/complete", requireJSON(s.completeTodo))
+	s.webRoutes(mux)
+	return mux
+}
+
+// todoRequest is the body of POST /todos and PATCH /todos/{id}. Fields
+// left out of a PATCH are kept. ParentID can only be set on creation.
+type todoRequest struct {
+	ParentID    *int      `json:"parentId"`
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Due         *string   `json:"due"`
+	Recurrence  *string   `json:"recurrence"`
+	Priority    *string   `json:"priority"`
+	Tags        *[]string `json:"tags"`
+}
+
+// edit converts the request into an edit of a todo currently due at due.
+func (r *todoRequest) edit(due time.Time) (todoEdit, error) {
+	e := todoEdit{
+		title:       r.Title,
+		description: r.Description,
+	}
+	if r.Due != nil {
+		var err error
+		if due, err = parseRequestDue(*r.Due); err != nil {
+			return todoEdit{}, err
+		}
+		e.due = &due
+	}
+	if r.Recurrence != nil {
+		repeat, err := parseRecurrence(*r.Recurrence, due)
+		if err != nil {
+			return todoEdit{}, err
+		}
+		e.recurrence = &repeat
+	}
+	if r.Priority != nil {
+		p, err := parsePriority(*r.Priority)
+		if err != nil {
+			return todoEdit{}, err
+		}
+		e.priority = &p
+	}
+	if r.Tags != nil {
+		tags := parseTags(strings.Join(*r.Tags, ","))
+		e.tags = &tags
+	}
+	return e, nil
+}
+
+// parseRequestDue accepts the RFC 3339 timestamps the API responds with as
+// well as the dates typed into the menu.
+func parseRequestDue(s string) (time.Time, error) {
+	if due, err := time.Parse(time.RFC3339, s); err == nil {
+		return due.In(time.Local), nil
+	}
+	return parseDue(s)
+}
+
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *server) listTodos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	st, err := parseStatus(query.Get("status"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sortBy, err := parseSortKey(query.Get("sort"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	overdue := false
+	if v := query.Get("overdue"); v != "" {
+		if overdue, err = strconv.ParseBool(v); err != nil {
+			writeError(w, invalidInputError(fmt.Sprintf("%q is not a valid overdue flag", v)))
+			return
+		}
+	}
+	opts := listOptions{
+		tag:     query.Get("tag"),
+		status:  st,
+		overdue: overdue,
+		sortBy:  sortBy,
+	}
+
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	todos := l.listTodos(opts, time.Now())
+	records := make([]todoRecord, 0, len(todos))
+	for _, t := range todos {
+		records = append(records, t.record())
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (s *server) createTodo(w http.ResponseWriter, r *http.Request) {
+	var req todoRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := req.edit(time.Time{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t := newTodo("", "")
+	e.apply(t)
+	if req.ParentID != nil {
+		t.parentID = *req.ParentID
+	}
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t, err = l.addTodo(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/todos/%d", t.id))
+	writeJSON(w, http.StatusCreated, t.record())
+}
+
+func (s *server) getTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeTodo(w, id)
+}
+
+func (s *server) updateTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req todoRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.ParentID != nil {
+		writeError(w, invalidInputError("parentId cannot be changed"))
+		return
+	}
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	current, err := l.getTodo(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	e, err := req.edit(current.due)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := l.editTodo(id, e); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeTodo(w, id)
+}
+
+func (s *server) deleteTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	policy, err := parseSubtaskPolicy(r.URL.Query().Get("subtasks"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := l.removeTodoWith(id, policy); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) completeTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	withSubtasks := false
+	if v := r.URL.Query().Get("subtasks"); v != "" {
+		if withSubtasks, err = strconv.ParseBool(v); err != nil {
+			writeError(w, invalidInputError(fmt.Sprintf("%q is not a valid subtasks flag", v)))
+			return
+		}
+	}
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := l.completeTodoWith(id, withSubtasks); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeTodo(w, id)
+}
+
+func (s *server) writeTodo(w http.ResponseWriter, id int) {
+	l, err := s.todoList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t, err := l.getTodo(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.record())
+}
+
+const maxBodySize = 1 << 20
+
+// requireJSON rejects requests that are not sent as application/json, even
+// those without a body. A browser can't send that content type cross-origin
+// without a CORS preflight, so other sites can't change todos through
+// plain HTML forms.
+func requireJSON(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mt != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: "Content-Type must be application/json",
+			})
+			return
+		}
+		h(w, r)
+	}
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidInputError(fmt.Sprintf("invalid JSON body: %v", err))
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError maps err onto a status code and writes it as an
+// errorResponse. Errors that aren't the client's fault are not shown to it.
+func writeError(w http.ResponseWriter, err error) {
+	res := errorResponse{Code: http.StatusInternalServerError, Message: "internal server error"}
+	switch errorKind(err) {
+	case todoErrorKindNotFound:
+		res = errorResponse{Code: http.StatusNotFound, Message: err.Error()}
+	case todoErrorKindInvalidID, todoErrorKindInvalidInput:
+		res = errorResponse{Code: http.StatusBadRequest, Message: err.Error()}
+	case todoErrorKindHasSubtasks:
+		res = errorResponse{Code: http.StatusConflict, Message: err.Error()}
+	default:
+		log.Printf("error handling request: %v", err)
+	}
+	writeJSON(w, res.Code, res)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func serve(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	if method != "GET" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("error decoding response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func TestServer_Todos(t *testing.T) {
+	list := newTestTodoList(t, "")
+	h := newServer(list).routes()
+
+	rec := serve(t, h, "POST", "/todos", `{"title":"deploy","description":"v2","due":"2024-03-01 09:00","priority":"high","tags":["work"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/todos/1" {
+		t.Errorf("expected Location /todos/1, got %q", loc)
+	}
+	created := decodeBody[todoRecord](t, rec)
+	if created.ID != 1 || created.Title != "deploy" || created.Priority != "high" || created.Due == nil {
+		t.Errorf("unexpected todo %+v", created)
+	}
+
+	serve(t, h, "POST", "/todos", `{"title":"groceries","tags":["home"]}`)
+
+	rec = serve(t, h, "GET", "/todos?tag=work", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d", rec.Code)
+	}
+	if todos := decodeBody[[]todoRecord](t, rec); len(todos) != 1 || todos[0].ID != 1 {
+		t.Errorf("unexpected todos %+v", todos)
+	}
+
+	rec = serve(t, h, "PATCH", "/todos/2", `{"description":"milk","due":"2024-03-02T10:00:00Z"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if td := decodeBody[todoRecord](t, rec); td.Title != "groceries" || td.Description != "milk" || td.Due == nil {
+		t.Errorf("unexpected todo %+v", td)
+	}
+
+	rec = serve(t, h, "POST", "/todos/2/complete", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d", rec.Code)
+	}
+	if td := decodeBody[todoRecord](t, rec); !td.Completed {
+		t.Error("expected the todo to be completed")
+	}
+
+	rec = serve(t, h, "GET", "/todos/2", "")
+	if td := decodeBody[todoRecord](t, rec); rec.Code != http.StatusOK || td.ID != 2 {
+		t.Errorf("get: unexpected %d %+v", rec.Code, td)
+	}
+
+	if rec = serve(t, h, "DELETE", "/todos/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", rec.Code)
+	}
+	if len(list.todos) != 1 {
+		t.Errorf("expected 1 todo left, got %d", len(list.todos))
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("title", ""))
+	h := newServer(list).routes()
+
+	tests := []struct {
+		method, target, body string
+		want                 int
+	}{
+		{"GET", "/todos/42", "", http.StatusNotFound},
+		{"GET", "/todos/abc", "", http.StatusBadRequest},
+		{"DELETE", "/todos/42", "", http.StatusNotFound},
+		{"POST", "/todos/42/complete", "", http.StatusNotFound},
+		{"POST", "/todos", `{"description":"no title"}`, http.StatusBadRequest},
+		{"POST", "/todos", `{"title":`, http.StatusBadRequest},
+		{"POST", "/todos", `{"title":"x","unknown":1}`, http.StatusBadRequest},
+		{"POST", "/todos", `{"title":"x","priority":"urgent"}`, http.StatusBadRequest},
+		{"PATCH", "/todos/1", `{"title":""}`, http.StatusBadRequest},
+		{"PATCH", "/todos/1", `{"due":"someday"}`, http.StatusBadRequest},
+		{"GET", "/todos?status=later", "", http.StatusBadRequest},
+		{"GET", "/todos?overdue=maybe", "", http.StatusBadRequest},
+		{"PUT", "/todos/1", `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, tt.method, tt.target, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.target, tt.want, rec.Code)
+			continue
+		}
+		if tt.want == http.StatusMethodNotAllowed {
+			continue
+		}
+		if res := decodeBody[errorResponse](t, rec); res.Code != tt.want || res.Message == "" {
+			t.Errorf("%s %s: unexpected error body %+v", tt.method, tt.target, res)
+		}
+	}
+}
+
+func TestServer_Concurrent(t *testing.T) {
+	list := newTestTodoList(t, "")
+	h := newServer(list).routes()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			serve(t, h, "POST", "/todos", `{"title":"title"}`)
+		}()
+		go func() {
+			defer wg.Done()
+			serve(t, h, "GET", "/todos", "")
+		}()
+	}
+	wg.Wait()
+
+	if len(list.todos) != 20 {
+		t.Errorf("expected 20 todos, got %d", len(list.todos))
+	}
+}
+
+func TestServer_ListChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	srv := newClosingTestStore(t, path)
+	cli := newClosingTestStore(t, path)
+	h := newServer(srv.currentList()).routes()
+
+	cli.currentList().addTodos(newTodo("fromcli", ""))
+	rec := serve(t, h, "GET", "/todos", "")
+	if todos := decodeBody[[]todoRecord](t, rec); len(todos) != 1 || todos[0].Title != "fromcli" {
+		t.Errorf("expected the todo added by the other process, got %+v", todos)
+	}
+
+	if err := cli.renameList(defaultListName, "home"); err != nil {
+		t.Fatalf("error renaming list: %v", err)
+	}
+	for _, tt := range []struct{ method, target, body string }{
+		{"GET", "/todos", ""},
+		{"POST", "/todos", `{"title":"fromserver"}`},
+		{"GET", "/todos/1", ""},
+		{"GET", "/", ""},
+	} {
+		rec := serve(t, h, tt.method, tt.target, tt.body)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tt.method, tt.target, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "no list named") {
+			t.Errorf("%s %s: expected the missing list in %q", tt.method, tt.target, rec.Body)
+		}
+	}
+	if got := titles(newClosingTestStore(t, path).currentList()); got != "fromcli" {
+		t.Errorf("expected nothing added to the renamed list, got %q", got)
+	}
+}
+
+func TestServer_RequireJSON(t *testing.T) {
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("title", ""))
+	h := newServer(list).routes()
+
+	tests := []struct {
+		method, target, contentType, body string
+	}{
+		{"POST", "/todos/1/complete", "application/x-www-form-urlencoded", ""},
+		{"POST", "/todos", "text/plain", `{"title":"forged"}`},
+		{"PATCH", "/todos/1", "multipart/form-data; boundary=x", `{"title":"forged"}`},
+		{"DELETE", "/todos/1", "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("%s %s as %q: expected 415, got %d", tt.method, tt.target, tt.contentType, rec.Code)
+		}
+	}
+	if got := titles(list); got != "title" {
+		t.Errorf("expected the list to be unchanged, got %q", got)
+	}
+
+	req := httptest.NewRequest("POST", "/todos/1/complete", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with a charset, got %d", rec.Code)
+	}
+}
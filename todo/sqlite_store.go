@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations upgrade the schema one version at a time. Version n is
+// migrations[n-1]; add new versions at the end and never edit old ones.
+var migrations = []string{
+	`CREATE TABLE lists (
+		name     TEXT PRIMARY KEY,
+		position INTEGER NOT NULL
+	);
+	CREATE TABLE todos (
+		id          INTEGER PRIMARY KEY,
+		list        TEXT NOT NULL,
+		position    INTEGER NOT NULL,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		completed   INTEGER NOT NULL DEFAULT 0,
+		due         TEXT NOT NULL DEFAULT '',
+		priority    TEXT NOT NULL DEFAULT '',
+		tags        TEXT NOT NULL DEFAULT '[]',
+		recurrence  TEXT NOT NULL DEFAULT '',
+		parent_id   INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX todos_list ON todos (list, position);
+	CREATE TABLE settings (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+	`CREATE TABLE reminders (
+		todo_id INTEGER NOT NULL,
+		due     TEXT NOT NULL,
+		before  TEXT NOT NULL,
+		PRIMARY KEY (todo_id, due, before)
+	);`,
+}
+
+// sqlTodoStore keeps the lists in a SQLite database. Lock begins a write
+// transaction, which SQLite allows one process at a time, and Save
+// writes each change with its own statements and commits it, so other
+// processes' rows are never written over. Load only reads the database
+// again after another process committed a change.
+type sqlTodoStore struct {
+	db *sql.DB
+	// conn is the one connection used, data_version only tells about
+	// changes of other connections from the same one.
+	conn *sql.Conn
+	tx   *sql.Tx
+	// version is the data_version of the last Load, -1 to load again.
+	version int64
+	nextID  int
+	current string
+}
+
+// todoRow is a todo as stored in the todos table.
+type todoRow struct {
+	list        string
+	position    int
+	title       string
+	description string
+	completed   bool
+	due         string
+	priority    string
+	tags        string
+	recurrence  string
+	parentID    int
+}
+
+func openSQLTodoStore(path string) (*sqlTodoStore, error) {
+	// transactions take the write lock right away, so two processes
+	// never both read and then write, and wait for each other
+	db, err := sql.Open("sqlite", "file:"+path+"?_txlock=immediate&_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	s := &sqlTodoStore{db: db, conn: conn, version: -1}
+	if err := s.migrate(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("error migrating %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// migrate brings the schema up to the latest version, recorded in
+// SQLite's user_version. The version is read in the transaction that
+// upgrades it, so two processes opening a new database don't both
+// migrate it.
+func (s *sqlTodoStore) migrate() error {
+	for {
+		done, err := s.migrateOnce()
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// migrateOnce upgrades the schema by one version and reports whether it
+// was up to date already.
+func (s *sqlTodoStore) migrateOnce() (bool, error) {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return false, err
+	}
+	if version > len(migrations) {
+		return false, fmt.Errorf("schema version %d is newer than this program", version)
+	}
+	if version == len(migrations) {
+		return true, nil
+	}
+	if _, err := tx.Exec(migrations[version]); err != nil {
+		return false, fmt.Errorf("version %d: %w", version+1, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+		return false, err
+	}
+	return false, tx.Commit()
+}
+
+// Load reads the lists if another process changed them since the last
+// Load, all in one transaction so it never sees half of a change.
+func (s *sqlTodoStore) Load() (*storageData, error) {
+	tx := s.tx
+	if tx == nil {
+		var err error
+		if tx, err = s.conn.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err != nil {
+			return nil, fmt.Errorf("error reading todos: %w", err)
+		}
+		defer tx.Rollback()
+	}
+	var version int64
+	if err := tx.QueryRow("PRAGMA data_version").Scan(&version); err != nil {
+		return nil, fmt.Errorf("error reading todos: %w", err)
+	}
+	if version == s.version {
+		return nil, nil
+	}
+	data, err := load(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.version, s.nextID, s.current = version, data.NextID, data.Current
+	return data, nil
+}
+
+func load(tx *sql.Tx) (*storageData, error) {
+	data := &storageData{}
+	rows, err := tx.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("error reading settings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("error reading settings: %w", err)
+		}
+		switch key {
+		case "nextId":
+			data.NextID, _ = strconv.Atoi(value)
+		case "current":
+			data.Current = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading settings: %w", err)
+	}
+
+	lists := make(map[string]int)
+	rows, err = tx.Query("SELECT name FROM lists ORDER BY position")
+	if err != nil {
+		return nil, fmt.Errorf("error reading lists: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lr listRecord
+		if err := rows.Scan(&lr.Name); err != nil {
+			return nil, fmt.Errorf("error reading lists: %w", err)
+		}
+		lists[lr.Name] = len(data.Lists)
+		data.Lists = append(data.Lists, lr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading lists: %w", err)
+	}
+
+	rows, err = tx.Query(`SELECT id, list, position, title, description, completed, due, priority, tags, recurrence, parent_id
+		FROM todos ORDER BY list, position`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading todos: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var row todoRow
+		err := rows.Scan(&id, &row.list, &row.position, &row.title, &row.description, &row.completed,
+			&row.due, &row.priority, &row.tags, &row.recurrence, &row.parentID)
+		if err != nil {
+			return nil, fmt.Errorf("error reading todos: %w", err)
+		}
+		i, ok := lists[row.list]
+		if !ok {
+			return nil, fmt.Errorf("todo %d is in unknown list %q", id, row.list)
+		}
+		r, err := row.record(id)
+		if err != nil {
+			return nil, fmt.Errorf("error reading todo %d: %w", id, err)
+		}
+		data.Lists[i].Todos = append(data.Lists[i].Todos, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading todos: %w", err)
+	}
+
+	rows, err = tx.Query("SELECT todo_id, due, before FROM reminders ORDER BY todo_id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading reminders: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r reminderRecord
+		var due string
+		if err := rows.Scan(&r.TodoID, &due, &r.Before); err != nil {
+			return nil, fmt.Errorf("error reading reminders: %w", err)
+		}
+		if r.Due, err = time.Parse(time.RFC3339, due); err != nil {
+			return nil, fmt.Errorf("error reading reminder for todo %d: %w", r.TodoID, err)
+		}
+		data.Reminders = append(data.Reminders, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading reminders: %w", err)
+	}
+	return data, nil
+}
+
+func (s *sqlTodoStore) Lock() error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("error locking todos: %w", err)
+	}
+	s.tx = tx
+	return nil
+}
+
+func (s *sqlTodoStore) Unlock() {
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.tx = nil
+	}
+}
+
+// Save writes the changes in the transaction begun by Lock and commits
+// it. If it fails the next Load reads everything again.
+func (s *sqlTodoStore) Save(c *changes) error {
+	tx := s.tx
+	s.tx = nil
+	if tx == nil {
+		return fmt.Errorf("error saving todos: not locked")
+	}
+	if err := s.write(tx, c); err != nil {
+		tx.Rollback()
+		s.version = -1
+		return fmt.Errorf("error saving todos: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.version = -1
+		return fmt.Errorf("error saving todos: %w", err)
+	}
+	s.nextID, s.current = c.nextID, c.current
+	return nil
+}
+
+func (s *sqlTodoStore) write(tx *sql.Tx, c *changes) error {
+	if c.nextID != s.nextID {
+		if err := setSetting(tx, "nextId", strconv.Itoa(c.nextID)); err != nil {
+			return err
+		}
+	}
+	if c.current != s.current {
+		if err := setSetting(tx, "current", c.current); err != nil {
+			return err
+		}
+	}
+	for _, ch := range c.log {
+		var err error
+		switch ch.kind {
+		case todoInserted:
+			err = insertTodo(tx, ch.list, ch.index, ch.record)
+		case todoUpdated:
+			err = updateTodo(tx, ch.record)
+		case todoDeleted:
+			err = deleteTodo(tx, ch.list, ch.index, ch.record.ID)
+		case listCreated:
+			_, err = tx.Exec("INSERT INTO lists (name, position) VALUES (?, ?)", ch.list, ch.index)
+		case listRenamed:
+			err = renameList(tx, ch.list, ch.name)
+		case listDeleted:
+			err = deleteList(tx, ch.list, ch.index)
+		case reminderSent:
+			_, err = tx.Exec("INSERT INTO reminders (todo_id, due, before) VALUES (?, ?, ?)",
+				ch.reminder.TodoID, ch.reminder.Due.UTC().Format(time.RFC3339), ch.reminder.Before)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setSetting(tx *sql.Tx, key, value string) error {
+	_, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+// insertTodo inserts a new todo. It has no ON CONFLICT clause, a todo
+// with the same ID fails the change instead of being written over.
+func insertTodo(tx *sql.Tx, list string, position int, r todoRecord) error {
+	_, err := tx.Exec("UPDATE todos SET position = position + 1 WHERE list = ? AND position >= ?", list, position)
+	if err != nil {
+		return err
+	}
+	row := newTodoRow(list, position, r)
+	_, err = tx.Exec(`INSERT INTO todos (id, list, position, title, description, completed, due, priority, tags, recurrence, parent_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, row.list, row.position, row.title, row.description, row.completed,
+		row.due, row.priority, row.tags, row.recurrence, row.parentID)
+	return err
+}
+
+// updateTodo updates a todo and forgets the reminders for the due date
+// it had before.
+func updateTodo(tx *sql.Tx, r todoRecord) error {
+	row := newTodoRow("", 0, r)
+	_, err := tx.Exec(`UPDATE todos SET title = ?, description = ?, completed = ?, due = ?, priority = ?, tags = ?, recurrence = ?, parent_id = ?
+		WHERE id = ?`,
+		row.title, row.description, row.completed, row.due, row.priority, row.tags, row.recurrence, row.parentID, r.ID)
+	if err != nil {
+		return err
+	}
+	var due string
+	if r.Due != nil {
+		due = r.Due.UTC().Truncate(time.Second).Format(time.RFC3339)
+	}
+	_, err = tx.Exec("DELETE FROM reminders WHERE todo_id = ? AND due != ?", r.ID, due)
+	return err
+}
+
+func deleteTodo(tx *sql.Tx, list string, position, id int) error {
+	if _, err := tx.Exec("DELETE FROM todos WHERE id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM reminders WHERE todo_id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE todos SET position = position - 1 WHERE list = ? AND position > ?", list, position)
+	return err
+}
+
+func renameList(tx *sql.Tx, oldName, newName string) error {
+	if _, err := tx.Exec("UPDATE lists SET name = ? WHERE name = ?", newName, oldName); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE todos SET list = ? WHERE list = ?", newName, oldName)
+	return err
+}
+
+func deleteList(tx *sql.Tx, name string, position int) error {
+	if _, err := tx.Exec("DELETE FROM reminders WHERE todo_id IN (SELECT id FROM todos WHERE list = ?)", name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM todos WHERE list = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM lists WHERE name = ?", name); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE lists SET position = position - 1 WHERE position > ?", position)
+	return err
+}
+
+func (s *sqlTodoStore) Close() error {
+	s.Unlock()
+	s.conn.Close()
+	return s.db.Close()
+}
+
+func newTodoRow(list string, position int, r todoRecord) todoRow {
+	row := todoRow{
+		list:        list,
+		position:    position,
+		title:       r.Title,
+		description: r.Description,
+		completed:   r.Completed,
+		priority:    r.Priority,
+		recurrence:  r.Recurrence,
+		parentID:    r.ParentID,
+	}
+	if r.Due != nil {
+		row.due = r.Due.Format(time.RFC3339Nano)
+	}
+	tags, _ := json.Marshal(r.Tags)
+	row.tags = string(tags)
+	return row
+}
+
+func (row todoRow) record(id int) (todoRecord, error) {
+	r := todoRecord{
+		ID:          id,
+		Title:       row.title,
+		Description: row.description,
+		Completed:   row.completed,
+		Priority:    row.priority,
+		Recurrence:  row.recurrence,
+		ParentID:    row.parentID,
+	}
+	if row.due != "" {
+		due, err := time.Parse(time.RFC3339Nano, row.due)
+		if err != nil {
+			return todoRecord{}, fmt.Errorf("invalid due date %q: %w", row.due, err)
+		}
+		r.Due = &due
+	}
+	if err := json.Unmarshal([]byte(row.tags), &r.Tags); err != nil {
+		return todoRecord{}, fmt.Errorf("invalid tags %q: %w", row.tags, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jsonTodoStore keeps the lists in a JSON file on disk. Changes are
+// serialized by a lock on a file next to it, and Load tells a changed file
+// by its size, modification time and identity, which every Save renews.
+type jsonTodoStore struct {
+	path   string
+	lock   *os.File
+	loaded os.FileInfo
+}
+
+type todoRecord struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Due         *time.Time `json:"due,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"`
+	ParentID    int        `json:"parentId,omitempty"`
+	Progress    string     `json:"progress,omitempty"`
+}
+
+type listRecord struct {
+	Name  string       `json:"name"`
+	Todos []todoRecord `json:"todos"`
+}
+
+type storageData struct {
+	NextID  int          `json:"nextId"`
+	Current string       `json:"current,omitempty"`
+	Lists   []listRecord `json:"lists,omitempty"`
+	// Todos is the single list of files written before named lists.
+	Todos     []todoRecord     `json:"todos,omitempty"`
+	Reminders []reminderRecord `json:"reminders,omitempty"`
+}
+
+func newJSONTodoStore(path string) *jsonTodoStore {
+	return &jsonTodoStore{
+		path: path,
+	}
+}
+
+// Load reads the stored data from the file. A missing file is an empty list.
+func (s *jsonTodoStore) Load() (*storageData, error) {
+	var data storageData
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.loaded = nil
+		return &data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.path, err)
+	}
+	defer f.Close()
+	// stat the open file, a Save renaming another one into place can't
+	// come between
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.path, err)
+	}
+	if s.unchanged(fi) {
+		return nil, nil
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", s.path, err)
+	}
+	s.loaded = fi
+	return &data, nil
+}
+
+func (s *jsonTodoStore) unchanged(fi os.FileInfo) bool {
+	return s.loaded != nil && os.SameFile(s.loaded, fi) &&
+		s.loaded.Size() == fi.Size() && s.loaded.ModTime().Equal(fi.ModTime())
+}
+
+// Lock locks the file path + ".lock".
+func (s *jsonTodoStore) Lock() error {
+	f, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("error locking %s: %w", s.path, err)
+	}
+	s.lock = f
+	return nil
+}
+
+func (s *jsonTodoStore) Unlock() {
+	if s.lock != nil {
+		s.lock.Close()
+		s.lock = nil
+	}
+}
+
+// Save writes all lists to a temporary file next to the target and
+// renames it into place, so a crash mid-write leaves the previous file
+// intact.
+func (s *jsonTodoStore) Save(c *changes) error {
+	s.loaded = nil
+	b, err := json.MarshalIndent(c.all(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding todos: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %w", tmp.Name(), s.path, err)
+	}
+	// the lock keeps others from replacing the file before this
+	if fi, err := os.Stat(s.path); err == nil {
+		s.loaded = fi
+	}
+	return nil
+}
+
+func (s *jsonTodoStore) Close() error {
+	return nil
+}
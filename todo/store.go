@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const defaultListName = "default"
+
+// store holds the named todo lists saved together in a TodoStore. The
+// lists share its lock and its ID sequence, so IDs are unique across
+// lists and a todo keeps its ID when it is moved to another list.
+type store struct {
+	mu        sync.Mutex
+	lists     []*todoList
+	current   *todoList
+	nextID    int
+	todoStore TodoStore
+	// reminded holds the reminders that went off.
+	reminded map[reminderKey]bool
+	// log holds the changes not saved yet.
+	log []change
+	// listsStored is false while the default list of an empty store
+	// exists only in memory.
+	listsStored bool
+}
+
+// openStore loads the lists stored at path, see openTodoStore.
+func openStore(path string) (*store, error) {
+	ts, err := openTodoStore(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newStore(ts)
+	if err != nil {
+		ts.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newStore loads the lists kept in ts.
+func newStore(ts TodoStore) (*store, error) {
+	s := &store{nextID: 1, todoStore: ts, reminded: make(map[reminderKey]bool)}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// refresh loads the lists again if another process saved them since
+// they were last loaded. s.mu must be held.
+func (s *store) refresh() error {
+	data, err := s.todoStore.Load()
+	if err != nil || data == nil {
+		return err
+	}
+	return s.load(data)
+}
+
+// load replaces the lists with data. Lists and todos that are still there
+// are updated in place, so the lists callers hold and the todos the undo
+// history refers to stay valid. s.mu must be held.
+func (s *store) load(data *storageData) error {
+	records := data.Lists
+	if len(records) == 0 && len(data.Todos) > 0 {
+		// files written before named lists hold a single list
+		records = []listRecord{{Name: defaultListName, Todos: data.Todos}}
+	}
+	nextID := max(s.nextID, data.NextID)
+	for _, lr := range records {
+		for _, r := range lr.Todos {
+			nextID = max(nextID, r.ID+1)
+		}
+	}
+	reminded := make(map[reminderKey]bool, len(data.Reminders))
+	for _, r := range data.Reminders {
+		key, err := newReminderKey(r)
+		if err != nil {
+			return fmt.Errorf("error loading reminder for todo %d: %w", r.TodoID, err)
+		}
+		reminded[key] = true
+	}
+
+	loaded := make([][]*todo, len(records))
+	for i, lr := range records {
+		for _, r := range lr.Todos {
+			t, err := newTodoFromRecord(r)
+			if err != nil {
+				return fmt.Errorf("error loading todo %d: %w", r.ID, err)
+			}
+			if t.id == 0 {
+				// files written before todos had IDs
+				t.id = nextID
+				nextID++
+			}
+			loaded[i] = append(loaded[i], t)
+		}
+	}
+
+	oldLists := make(map[string]*todoList, len(s.lists))
+	oldTodos := make(map[int]*todo)
+	for _, l := range s.lists {
+		oldLists[l.name] = l
+		for _, t := range l.todos {
+			oldTodos[t.id] = t
+		}
+	}
+	s.listsStored = len(records) > 0
+	if len(records) == 0 {
+		records = []listRecord{{Name: defaultListName}}
+		loaded = make([][]*todo, 1)
+	}
+	lists := make([]*todoList, 0, len(records))
+	for i, lr := range records {
+		l := oldLists[lr.Name]
+		if l == nil {
+			l = s.newList(lr.Name)
+		}
+		l.todos = loaded[i]
+		for j, t := range l.todos {
+			if old := oldTodos[t.id]; old != nil {
+				*old = *t
+				l.todos[j] = old
+			}
+		}
+		lists = append(lists, l)
+	}
+
+	s.lists = lists
+	s.nextID = nextID
+	s.reminded = reminded
+	s.current = s.lists[0]
+	if l, err := s.findList(data.Current); err == nil {
+		s.current = l
+	}
+	return nil
+}
+
+// update applies a change to the lists and saves it. It locks the
+// TodoStore and loads what other processes saved first, so the change
+// starts from what is stored and saving it loses none of their changes.
+// apply must return an error without changing anything if it fails.
+// s.mu must be held.
+func (s *store) update(apply func() error) error {
+	if err := s.todoStore.Lock(); err != nil {
+		return err
+	}
+	defer s.todoStore.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	s.log = nil
+	if !s.listsStored {
+		s.record(change{kind: listCreated, list: s.lists[0].name, index: 0})
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.listsStored = true
+	return nil
+}
+
+// record logs a change for the next save. s.mu must be held.
+func (s *store) record(c change) {
+	s.log = append(s.log, c)
+}
+
+// sync loads what other processes saved before the lists are read. If
+// that fails the lists are read as they were last loaded, the error is
+// only logged. s.mu must be held.
+func (s *store) sync() {
+	if err := s.refresh(); err != nil {
+		log.Printf("error reloading todos: %v", err)
+	}
+}
+
+func (s *store) newList(name string) *todoList {
+	return &todoList{
+		name:  name,
+		store: s,
+	}
+}
+
+// currentList returns the list the menu and commands work on.
+func (s *store) currentList() *todoList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sync()
+	return s.current
+}
+
+func (s *store) listNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sync()
+	names := make([]string, 0, len(s.lists))
+	for _, l := range s.lists {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+func (s *store) getList(name string) (*todoList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s.findList(name)
+}
+
+func (s *store) createList(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		name, err := s.checkNewName(name)
+		if err != nil {
+			return err
+		}
+		s.lists = append(s.lists, s.newList(name))
+		s.record(change{kind: listCreated, list: name, index: len(s.lists) - 1})
+		return nil
+	})
+}
+
+func (s *store) renameList(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		l, err := s.findList(oldName)
+		if err != nil {
+			return err
+		}
+		newName, err := s.checkNewName(newName)
+		if err != nil {
+			return err
+		}
+		s.record(change{kind: listRenamed, list: l.name, name: newName})
+		l.name = newName
+		return nil
+	})
+}
+
+// deleteList deletes the list and its todos. The last list can't be
+// deleted, deleting the current one switches to the first list left.
+func (s *store) deleteList(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		l, err := s.findList(name)
+		if err != nil {
+			return err
+		}
+		if len(s.lists) == 1 {
+			return invalidInputError("the last list can't be deleted")
+		}
+		i := slices.Index(s.lists, l)
+		s.lists = slices.Delete(s.lists, i, i+1)
+		s.record(change{kind: listDeleted, list: l.name, index: i})
+		if s.current == l {
+			s.current = s.lists[0]
+		}
+		return nil
+	})
+}
+
+func (s *store) switchList(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		l, err := s.findList(name)
+		if err != nil {
+			return err
+		}
+		s.current = l
+		return nil
+	})
+}
+
+// moveTodo moves the todo with the given ID to the named list. Moving
+// can't be undone, so it clears the undo history of both lists.
+func (s *store) moveTodo(id int, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() error {
+		target, err := s.findList(to)
+		if err != nil {
+			return err
+		}
+		for _, source := range s.lists {
+			t, err := source.find(id)
+			if err != nil {
+				continue
+			}
+			if source == target {
+				return nil
+			}
+			// A todo takes its subtasks along. A subtask moved on its own
+			// leaves its parent behind.
+			moved := append([]*todo{t}, source.children(t.id)...)
+			detached := *t
+			detached.parentID = 0
+			source.update(t, detached)
+			for _, t := range moved {
+				source.remove(t)
+				target.insert(len(target.todos), t)
+			}
+			source.history = history{}
+			target.history = history{}
+			return nil
+		}
+		return notFoundError(id)
+	})
+}
+
+// findList returns the named list. s.mu must be held.
+func (s *store) findList(name string) (*todoList, error) {
+	for _, l := range s.lists {
+		if l.name == name {
+			return l, nil
+		}
+	}
+	return nil, listNotFoundError(name)
+}
+
+func (s *store) checkNewName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", invalidInputError("list name must not be empty")
+	}
+	if _, err := s.findList(name); err == nil {
+		return "", invalidInputError(fmt.Sprintf("a list named %q already exists", name))
+	}
+	return name, nil
+}
+
+// save hands the changes to the TodoStore. s.mu must be held.
+func (s *store) save() error {
+	c := &changes{
+		all:     s.data,
+		nextID:  s.nextID,
+		current: s.current.name,
+		log:     s.log,
+	}
+	s.log = nil
+	return s.todoStore.Save(c)
+}
+
+// data returns all lists. s.mu must be held.
+func (s *store) data() *storageData {
+	data := &storageData{
+		NextID:  s.nextID,
+		Current: s.current.name,
+		Lists:   make([]listRecord, 0, len(s.lists)),
+	}
+	for _, l := range s.lists {
+		lr := listRecord{Name: l.name, Todos: make([]todoRecord, 0, len(l.todos))}
+		for _, t := range l.todos {
+			lr.Todos = append(lr.Todos, t.record())
+		}
+		data.Lists = append(data.Lists, lr)
+	}
+	data.Reminders = s.reminders()
+	return data
+}
+
+// reminders returns the reminders that went off, forgetting those of
+// todos that are gone or due at another time now. s.mu must be held.
+func (s *store) reminders() []reminderRecord {
+	dues := make(map[int]int64)
+	for _, l := range s.lists {
+		for _, t := range l.todos {
+			if !t.due.IsZero() {
+				dues[t.id] = t.due.Unix()
+			}
+		}
+	}
+	var records []reminderRecord
+	for key := range s.reminded {
+		if due, ok := dues[key.todoID]; !ok || due != key.due {
+			delete(s.reminded, key)
+			continue
+		}
+		records = append(records, key.record())
+	}
+	slices.SortFunc(records, func(a, b reminderRecord) int {
+		if a.TodoID != b.TodoID {
+			return a.TodoID - b.TodoID
+		}
+		return strings.Compare(a.Before, b.Before)
+	})
+	return records
+}
+
+// close closes the TodoStore.
+func (s *store) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.todoStore.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T, path string) *store {
+	t.Helper()
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatalf("error opening store: %v", err)
+	}
+	return s
+}
+
+func TestStore_Lists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	s := newTestStore(t, path)
+
+	if names := s.listNames(); !slices.Equal(names, []string{"default"}) {
+		t.Fatalf("expected a default list, got %v", names)
+	}
+	if err := s.createList("work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.createList(" personal "); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.createList("work"); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected a duplicate name to be rejected, got %v", err)
+	}
+	if err := s.createList(" "); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an empty name to be rejected, got %v", err)
+	}
+
+	s.currentList().addTodos(newTodo("laundry", ""))
+	if err := s.switchList("work"); err != nil {
+		t.Fatal(err)
+	}
+	work := s.currentList()
+	work.addTodos(newTodo("deploy", ""), newTodo("groceries", ""))
+
+	if err := s.moveTodo(3, "personal"); err != nil {
+		t.Fatalf("error moving todo: %v", err)
+	}
+	if err := s.moveTodo(42, "personal"); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if err := s.moveTodo(2, "nowhere"); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if err := work.undo(); errorKind(err) != todoErrorKindEmptyHistory {
+		t.Errorf("expected moving to clear the history, got %v", err)
+	}
+
+	if err := s.renameList("work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.renameList("work", "other"); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	loaded := newTestStore(t, path)
+	if names := loaded.listNames(); !slices.Equal(names, []string{"default", "job", "personal"}) {
+		t.Fatalf("unexpected lists %v", names)
+	}
+	if loaded.currentList().name != "job" {
+		t.Errorf("expected the current list to be saved, got %q", loaded.currentList().name)
+	}
+	personal, _ := loaded.getList("personal")
+	if len(personal.todos) != 1 || personal.todos[0].id != 3 || personal.todos[0].title != "groceries" {
+		t.Errorf("expected the moved todo to keep its ID, got %+v", personal.todos)
+	}
+	td := newTodo("new", "")
+	personal.addTodos(td)
+	if td.id != 4 {
+		t.Errorf("expected IDs to be unique across lists, got %d", td.id)
+	}
+
+	if err := loaded.deleteList("job"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.currentList().name != "default" {
+		t.Errorf("expected deleting the current list to switch lists, got %q", loaded.currentList().name)
+	}
+	loaded.deleteList("personal")
+	if err := loaded.deleteList("default"); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected the last list not to be deleted, got %v", err)
+	}
+}
+
+func TestStore_LoadSingleListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	data := `{"nextId": 3, "todos": [{"id": 1, "title": "first"}, {"id": 2, "title": "second"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestStore(t, path)
+	l := s.currentList()
+	if l.name != defaultListName || len(l.todos) != 2 {
+		t.Fatalf("expected the todos in the default list, got %q with %d todos", l.name, len(l.todos))
+	}
+}
+
+func TestMenu_Lists(t *testing.T) {
+	input := strings.Join([]string{
+		"12", "work",
+		"2", "deploy", "", "", "", "", "",
+		"16", "1", "work",
+		"13", "work",
+		"11",
+		"15", "default", "y",
+		"13", "nowhere",
+		"0",
+	}, "\n") + "\n"
+
+	s := newTestStore(t, "")
+	var out bytes.Buffer
+	m := newMenu(s, strings.NewReader(input), &out)
+	m.start()
+
+	want := menuPrompt("default") + "Name: " +
+		menuPrompt("default") + addPrompts +
+		menuPrompt("default") + "ID: To list: " +
+		menuPrompt("default") + "Name: " +
+		menuPrompt("work") + "Lists\n  default\n* work\n" +
+		menuPrompt("work") + "Name: Delete list \"default\" and all its todos? (y/N): " +
+		menuPrompt("work") + "Name: Sorry, no list named \"nowhere\". Please try again.\n" +
+		menuPrompt("work")
+	if out.String() != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if l := s.currentList(); len(l.todos) != 1 || l.todos[0].title != "deploy" {
+		t.Error("expected the todo to have been moved to work")
+	}
+}
+
+func TestStore_SharedFile(t *testing.T) {
+	for _, file := range []string{"todos.json", "todos.db"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			// the CLI and the server open the same file, each loading it
+			// before the other changed anything
+			cli := newClosingTestStore(t, path)
+			srv := newClosingTestStore(t, path)
+
+			fromCLI := newTodo("fromcli", "")
+			cli.currentList().addTodos(fromCLI)
+			fromServer := newTodo("fromserver", "")
+			srv.currentList().addTodos(fromServer)
+			if fromCLI.id == fromServer.id {
+				t.Errorf("expected different IDs, both got %d", fromCLI.id)
+			}
+			srv.createList("work")
+			// undo and redo in one process only set back what the command
+			// changed and keep the edits of the other
+			srv.currentList().completeTodo(fromCLI.id)
+			renamed := "renamed by cli"
+			cli.currentList().editTodo(fromCLI.id, todoEdit{title: &renamed})
+			srv.currentList().undo()
+			if got := titles(srv.currentList()); got != "renamed by cli,fromserver" {
+				t.Errorf("expected the completion to be undone only, got %q", got)
+			}
+			srv.currentList().redo()
+			edited := "edited by cli"
+			cli.currentList().editTodo(fromServer.id, todoEdit{title: &edited})
+			desc := "described by server"
+			srv.currentList().editTodo(fromServer.id, todoEdit{description: &desc})
+			cli.currentList().undo()
+
+			for name, s := range map[string]*store{"cli": cli, "server": srv, "reopened": newClosingTestStore(t, path)} {
+				l := s.currentList()
+				if got := titles(l); got != "renamed by cli*,fromserver" {
+					t.Errorf("%s: expected both todos, got %q", name, got)
+				}
+				if got, _ := l.getTodo(fromServer.id); got.description != desc {
+					t.Errorf("%s: expected the description to be kept, got %q", name, got.description)
+				}
+				if names := s.listNames(); !slices.Equal(names, []string{"default", "work"}) {
+					t.Errorf("%s: expected both lists, got %v", name, names)
+				}
+			}
+		})
+	}
+}
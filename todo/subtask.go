@@ -0,0 +1,107 @@
+package main
+
+import "fmt"
+
+// subtaskPolicy says what removing a todo does to its subtasks.
+type subtaskPolicy uint8
+
+const (
+	// subtasksRefuse fails the removal of a todo that has subtasks, so
+	// they are never lost by accident.
+	subtasksRefuse subtaskPolicy = iota
+	subtasksDelete
+	// subtasksDetach keeps the subtasks as todos of their own.
+	subtasksDetach
+)
+
+func parseSubtaskPolicy(s string) (subtaskPolicy, error) {
+	switch s {
+	case "":
+		return subtasksRefuse, nil
+	case "delete":
+		return subtasksDelete, nil
+	case "keep":
+		return subtasksDetach, nil
+	}
+	return 0, invalidInputError(fmt.Sprintf("%q is not a valid subtask option, use delete or keep", s))
+}
+
+// progress counts the completed subtasks of a todo.
+type progress struct {
+	total int
+	done  int
+}
+
+func (p progress) String() string {
+	return fmt.Sprintf("%d/%d done", p.done, p.total)
+}
+
+// children returns the subtasks of the todo with the given ID.
+func (l *todoList) children(id int) []*todo {
+	var children []*todo
+	for _, t := range l.todos {
+		if t.parentID == id {
+			children = append(children, t)
+		}
+	}
+	return children
+}
+
+func (l *todoList) progress(id int) progress {
+	var p progress
+	for _, t := range l.children(id) {
+		p.total++
+		if t.completed {
+			p.done++
+		}
+	}
+	return p
+}
+
+// subtasksDone reports whether the todo has subtasks and all of them are
+// completed.
+func (l *todoList) subtasksDone(id int) bool {
+	p := l.progress(id)
+	return p.total > 0 && p.done == p.total
+}
+
+// checkParent checks a new todo can be a subtask of the todo with the
+// given ID. Subtasks only go one level deep.
+func (l *todoList) checkParent(id int) error {
+	if id == 0 {
+		return nil
+	}
+	parent, err := l.find(id)
+	if err != nil {
+		return err
+	}
+	if parent.parentID != 0 {
+		return invalidInputError(fmt.Sprintf("todo %d is a subtask and cannot have subtasks of its own", id))
+	}
+	return nil
+}
+
+// view returns a copy of t with its progress filled in.
+func (l *todoList) view(t *todo) *todo {
+	c := t.clone()
+	c.progress = l.progress(t.id)
+	return c
+}
+
+// setCompleted sets whether t is completed. Completing a recurring todo
+// hands its recurrence on to a new todo for the next occurrence, which it
+// returns.
+func (l *todoList) setCompleted(t *todo, completed bool) *todo {
+	v := *t
+	var next *todo
+	if completed && !v.completed && v.recurrence.kind != recurNone {
+		next = v.nextOccurrence()
+		next.id = l.store.nextID
+		l.store.nextID++
+		l.insert(len(l.todos), next)
+		v.recurrence = recurrence{}
+	}
+	v.setCompleted(completed)
+	l.update(t, v)
+	return next
+}
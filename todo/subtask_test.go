@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newSubtask(title string, parentID int) *todo {
+	t := newTodo(title, "")
+	t.parentID = parentID
+	return t
+}
+
+func TestTodoList_Subtasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	list := newTestTodoList(t, path)
+	list.addTodos(newTodo("move", ""))
+	list.addTodos(newSubtask("pack", 1), newSubtask("rent van", 1), newTodo("other", ""))
+
+	if err := list.addTodos(newSubtask("boxes", 2)); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected subtasks of subtasks to be refused, got %v", err)
+	}
+	if err := list.addTodos(newSubtask("orphan", 42)); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a missing parent to be refused, got %v", err)
+	}
+
+	parent, _ := list.getTodo(1)
+	if parent.progress != (progress{total: 2}) {
+		t.Errorf("expected 0/2 done, got %s", parent.progress)
+	}
+
+	list.completeTodo(2)
+	if got := titles(list); got != "move,pack*,rent van,other" {
+		t.Errorf("expected the parent to stay open, got %q", got)
+	}
+	list.completeTodo(3)
+	if got := titles(list); got != "move*,pack*,rent van*,other" {
+		t.Errorf("expected the last subtask to complete the parent, got %q", got)
+	}
+	list.undo()
+	if got := titles(list); got != "move,pack*,rent van,other" {
+		t.Errorf("expected undo to reopen the parent, got %q", got)
+	}
+	list.undo()
+
+	list.completeTodo(1)
+	if got := titles(list); got != "move*,pack,rent van,other" {
+		t.Errorf("expected the subtasks to stay open, got %q", got)
+	}
+	list.undo()
+	list.completeTodoWith(1, true)
+	if got := titles(list); got != "move*,pack*,rent van*,other" {
+		t.Errorf("expected the subtasks to be completed too, got %q", got)
+	}
+
+	var buf bytes.Buffer
+	list.getTodos(&buf)
+	if out := buf.String(); !strings.Contains(out, "Progress:    2/2 done") || !strings.Contains(out, "Parent:      1") {
+		t.Errorf("expected progress and parent in output, got %q", out)
+	}
+
+	if got := newTestTodoList(t, path).todos[1].parentID; got != 1 {
+		t.Errorf("expected the parent to be saved, got %d", got)
+	}
+}
+
+func TestTodoList_RemoveParent(t *testing.T) {
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("move", ""), newTodo("other", ""))
+	list.addTodos(newSubtask("pack", 1), newSubtask("rent van", 1))
+
+	if err := list.removeTodo(1); errorKind(err) != todoErrorKindHasSubtasks {
+		t.Fatalf("expected removing a parent to be refused, got %v", err)
+	}
+
+	list.removeTodoWith(1, subtasksDelete)
+	if got := titles(list); got != "other" {
+		t.Errorf("expected the subtasks to be removed, got %q", got)
+	}
+	list.undo()
+	if got := titles(list); got != "move,other,pack,rent van" {
+		t.Errorf("expected undo to restore the order, got %q", got)
+	}
+	list.redo()
+	if got := titles(list); got != "other" {
+		t.Errorf("expected redo to remove the subtasks again, got %q", got)
+	}
+	list.undo()
+
+	list.removeTodoWith(1, subtasksDetach)
+	if got := titles(list); got != "other,pack,rent van" {
+		t.Errorf("expected the subtasks to be kept, got %q", got)
+	}
+	if list.todos[1].parentID != 0 || list.todos[2].parentID != 0 {
+		t.Error("expected the kept subtasks to be detached")
+	}
+	list.undo()
+	if list.todos[2].parentID != 1 {
+		t.Error("expected undo to attach the subtasks again")
+	}
+}
+
+func TestStore_MoveParent(t *testing.T) {
+	s := newTestStore(t, "")
+	s.createList("home")
+	l := s.currentList()
+	l.addTodos(newTodo("move", ""))
+	l.addTodos(newSubtask("pack", 1), newTodo("other", ""))
+
+	if err := s.moveTodo(1, "home"); err != nil {
+		t.Fatalf("error moving todo: %v", err)
+	}
+	home, _ := s.getList("home")
+	if got := titles(home); got != "move,pack" {
+		t.Errorf("expected the subtask to move along, got %q", got)
+	}
+	if got := titles(l); got != "other" {
+		t.Errorf("expected the source list to keep the rest, got %q", got)
+	}
+
+	s.moveTodo(2, defaultListName)
+	if pack, _ := l.getTodo(2); pack.parentID != 0 {
+		t.Errorf("expected a subtask moved on its own to be detached, got parent %d", pack.parentID)
+	}
+}
+
+func TestServer_Subtasks(t *testing.T) {
+	h := newServer(newTestTodoList(t, "")).routes()
+	serve(t, h, "POST", "/todos", `{"title":"move"}`)
+	if rec := serve(t, h, "POST", "/todos", `{"title":"pack","parentId":1}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	if rec := serve(t, h, "DELETE", "/todos/1", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", rec.Code)
+	}
+	if rec := serve(t, h, "PATCH", "/todos/2", `{"parentId":3}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+
+	rec := serve(t, h, "POST", "/todos/1/complete?subtasks=true", "")
+	if got := decodeBody[todoRecord](t, rec); !got.Completed || got.Progress != "1/1 done" {
+		t.Errorf("expected the parent and its subtask to be completed, got %+v", got)
+	}
+
+	if rec := serve(t, h, "DELETE", "/todos/1?subtasks=purge", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if rec := serve(t, h, "DELETE", "/todos/1?subtasks=delete", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if rec := serve(t, h, "GET", "/todos/2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected the subtask to be deleted, got %d", rec.Code)
+	}
+}
+
+func TestMenu_Subtasks(t *testing.T) {
+	s := newTestStore(t, "")
+	input := strings.Join([]string{
+		"2", "move", "", "", "", "", "",
+		"17", "1", "pack", "", "", "", "", "",
+		"4", "1", "y",
+		"3", "1", "",
+		"3", "1", "keep",
+		"0",
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	newMenu(s, strings.NewReader(input), &out).start()
+
+	want := []string{
+		"Parent ID: ",
+		"Complete its 1 open subtasks too? (y/N): ",
+		"Todo 1 has 1 subtasks, delete or keep them? (delete/keep): Sorry, todo 1 has 1 subtasks, say whether to delete or keep them. Please try again.",
+	}
+	for _, w := range want {
+		if !strings.Contains(out.String(), w) {
+			t.Errorf("expected output to contain %q, got %q", w, out.String())
+		}
+	}
+	if got := titles(s.currentList()); got != "pack*" {
+		t.Errorf("expected the completed subtask to be kept, got %q", got)
+	}
+}
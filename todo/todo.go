@@ -2,12 +2,119 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
 )
 
+type priority uint8
+
+const (
+	priorityNone priority = iota
+	priorityLow
+	priorityMedium
+	priorityHigh
+)
+
+func (p priority) String() string {
+	switch p {
+	case priorityLow:
+		return "low"
+	case priorityMedium:
+		return "medium"
+	case priorityHigh:
+		return "high"
+	default:
+		return ""
+	}
+}
+
+// parsePriority parses low, medium or high. An empty string is no priority.
+func parsePriority(s string) (priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return priorityNone, nil
+	case "low":
+		return priorityLow, nil
+	case "medium":
+		return priorityMedium, nil
+	case "high":
+		return priorityHigh, nil
+	default:
+		return priorityNone, invalidInputError(fmt.Sprintf("%q is not a valid priority, use low, medium or high", s))
+	}
+}
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04"
+)
+
+// parseDue parses a due date with an optional time of day. An empty
+// string is no due date.
+func parseDue(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{dateTimeLayout, dateLayout} {
+		if due, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return due, nil
+		}
+	}
+	return time.Time{}, invalidInputError(fmt.Sprintf("%q is not a valid due date, use YYYY-MM-DD or YYYY-MM-DD HH:MM", s))
+}
+
+// parseTags splits a comma separated list of tags, dropping blanks and
+// duplicates.
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDateOnly reports whether the due date was given without a time of day.
+func isDateOnly(due time.Time) bool {
+	h, m, s := due.Clock()
+	return h == 0 && m == 0 && s == 0 && due.Nanosecond() == 0
+}
+
+func formatDue(due time.Time) string {
+	if isDateOnly(due) {
+		return due.Format(dateLayout)
+	}
+	return due.Format(dateTimeLayout)
+}
+
 type todo struct {
+	id          int
 	title       string
 	description string
 	completed   bool
+	due         time.Time
+	priority    priority
+	tags        []string
+	recurrence  recurrence
+	parentID    int
+
+	// progress counts the subtasks of a copy handed out by the list. It
+	// is not stored.
+	progress progress
 }
 
 func newTodo(title, description string) *todo {
@@ -19,15 +126,187 @@ func newTodo(title, description string) *todo {
 }
 
 const printFormat = `
+ID:          %d
 Title:       %s
 Description: %s
 Completed:   %v
 `
 
-func (t *todo) print() {
-	fmt.Printf(printFormat, t.title, t.description, t.completed)
+func (t *todo) print(w io.Writer) {
+	fmt.Fprintf(w, printFormat, t.id, t.title, t.description, t.completed)
+	if !t.due.IsZero() {
+		fmt.Fprintf(w, "Due:         %s\n", formatDue(t.due))
+	}
+	if t.priority != priorityNone {
+		fmt.Fprintf(w, "Priority:    %s\n", t.priority)
+	}
+	if len(t.tags) > 0 {
+		fmt.Fprintf(w, "Tags:        %s\n", strings.Join(t.tags, ", "))
+	}
+	if t.recurrence.kind != recurNone {
+		fmt.Fprintf(w, "Repeats:     %s\n", t.recurrence)
+	}
+	if t.parentID != 0 {
+		fmt.Fprintf(w, "Parent:      %d\n", t.parentID)
+	}
+	if t.progress.total > 0 {
+		fmt.Fprintf(w, "Progress:    %s\n", t.progress)
+	}
+}
+
+// validate checks the todo can be added to a list.
+func (t *todo) validate() error {
+	if t.title == "" {
+		return invalidInputError("title must not be empty")
+	}
+	if t.recurrence.kind != recurNone && t.due.IsZero() {
+		return invalidInputError("a recurring todo needs a due date")
+	}
+	return nil
+}
+
+// nextOccurrence returns the open todo that follows a recurring todo,
+// due when its recurrence says.
+func (t *todo) nextOccurrence() *todo {
+	next := newTodo(t.title, t.description)
+	next.due = t.recurrence.next(t.due)
+	next.priority = t.priority
+	next.tags = slices.Clone(t.tags)
+	next.recurrence = t.recurrence
+	next.parentID = t.parentID
+	return next
+}
+
+func (t *todo) clone() *todo {
+	c := *t
+	c.tags = slices.Clone(t.tags)
+	return &c
 }
 
 func (t *todo) setCompleted(completed bool) {
 	t.completed = completed
 }
+
+// overdue reports whether the todo is still open after its deadline.
+func (t *todo) overdue(now time.Time) bool {
+	if t.completed || t.due.IsZero() {
+		return false
+	}
+	return !now.Before(t.deadline())
+}
+
+// deadline returns when the todo is due. A todo due on a date without a
+// time of day is due by the end of that day.
+func (t *todo) deadline() time.Time {
+	if isDateOnly(t.due) {
+		return t.due.AddDate(0, 0, 1)
+	}
+	return t.due
+}
+
+// todoEdit holds the changes to a todo. Nil fields are left unchanged.
+type todoEdit struct {
+	title       *string
+	description *string
+	due         *time.Time
+	priority    *priority
+	tags        *[]string
+	recurrence  *recurrence
+}
+
+func (e todoEdit) apply(t *todo) {
+	if e.title != nil {
+		t.title = *e.title
+	}
+	if e.description != nil {
+		t.description = *e.description
+	}
+	if e.due != nil {
+		t.due = *e.due
+	}
+	if e.priority != nil {
+		t.priority = *e.priority
+	}
+	if e.tags != nil {
+		t.tags = *e.tags
+	}
+	if e.recurrence != nil {
+		t.recurrence = *e.recurrence
+	}
+}
+
+// revert returns the edit that sets the fields e sets back to their
+// values in t.
+func (e todoEdit) revert(t *todo) todoEdit {
+	// copy t, the edit must not point into the todo it reverts
+	old := *t
+	old.tags = slices.Clone(t.tags)
+	var r todoEdit
+	if e.title != nil {
+		r.title = &old.title
+	}
+	if e.description != nil {
+		r.description = &old.description
+	}
+	if e.due != nil {
+		r.due = &old.due
+	}
+	if e.priority != nil {
+		r.priority = &old.priority
+	}
+	if e.tags != nil {
+		r.tags = &old.tags
+	}
+	if e.recurrence != nil {
+		r.recurrence = &old.recurrence
+	}
+	return r
+}
+
+func (t *todo) hasTag(tag string) bool {
+	return containsTag(t.tags, tag)
+}
+
+func (t *todo) record() todoRecord {
+	r := todoRecord{
+		ID:          t.id,
+		Title:       t.title,
+		Description: t.description,
+		Completed:   t.completed,
+		Priority:    t.priority.String(),
+		Tags:        t.tags,
+		Recurrence:  t.recurrence.String(),
+		ParentID:    t.parentID,
+	}
+	if t.progress.total > 0 {
+		r.Progress = t.progress.String()
+	}
+	if !t.due.IsZero() {
+		due := t.due
+		r.Due = &due
+	}
+	return r
+}
+
+func newTodoFromRecord(r todoRecord) (*todo, error) {
+	p, err := parsePriority(r.Priority)
+	if err != nil {
+		return nil, err
+	}
+	t := &todo{
+		id:          r.ID,
+		title:       r.Title,
+		description: r.Description,
+		completed:   r.Completed,
+		priority:    p,
+		tags:        r.Tags,
+		parentID:    r.ParentID,
+	}
+	if r.Due != nil {
+		t.due = r.Due.In(time.Local)
+	}
+	if t.recurrence, err = parseRecurrence(r.Recurrence, t.due); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
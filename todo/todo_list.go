@@ -1,45 +1,261 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
 
+// todoList is one of the named lists of a store. It is safe for
+// concurrent use, so the menu and the HTTP server can share one list.
 type todoList struct {
-	todos []*todo
+	name    string
+	store   *store
+	todos   []*todo
+	history history
 }
 
-func newTodoList() *todoList {
-	return &todoList{}
+// newTodoList opens the store at path and returns its current list. An
+// empty path keeps the lists in memory only.
+func newTodoList(path string) (*todoList, error) {
+	s, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.currentList(), nil
 }
 
-func (l *todoList) getTodos() {
-	fmt.Println("List of todos")
-	if len(l.todos) == 0 {
-		fmt.Println("(empty)")
+func (l *todoList) getTodos(w io.Writer) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.sync()
+	todos := make([]*todo, len(l.todos))
+	for i, t := range l.todos {
+		todos[i] = l.view(t)
 	}
+	printTodos(w, todos)
+}
+
+// listTodos returns copies of the todos matching opts in the order it
+// asks for.
+func (l *todoList) listTodos(opts listOptions, now time.Time) []*todo {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.sync()
+	var todos []*todo
 	for _, t := range l.todos {
-		t.print()
+		if opts.match(t, now) {
+			todos = append(todos, l.view(t))
+		}
+	}
+	sortTodos(todos, opts.sortBy)
+	return todos
+}
+
+func printTodos(w io.Writer, todos []*todo) {
+	fmt.Fprintln(w, "List of todos")
+	if len(todos) == 0 {
+		fmt.Fprintln(w, "(empty)")
+	}
+	for _, t := range todos {
+		t.print(w)
+	}
+}
+
+// addTodos assigns each todo the next free ID and appends it to the list.
+func (l *todoList) addTodos(todos ...*todo) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&addTodosCommand{todos: todos})
+}
+
+// addTodosWith adds todos, some of which may be subtasks of others added
+// with them. parents maps each such subtask to its parent.
+func (l *todoList) addTodosWith(todos []*todo, parents map[*todo]*todo) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&addTodosCommand{todos: todos, parents: parents})
+}
+
+// addTodo adds a single todo and returns a copy of it with its ID.
+func (l *todoList) addTodo(t *todo) (*todo, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if err := l.execute(&addTodosCommand{todos: []*todo{t}}); err != nil {
+		return nil, err
 	}
+	return t.clone(), nil
+}
+
+// removeTodo removes a todo, failing if it has subtasks.
+func (l *todoList) removeTodo(id int) error {
+	return l.removeTodoWith(id, subtasksRefuse)
+}
+
+// removeTodoWith removes a todo and deals with its subtasks as the policy
+// says.
+func (l *todoList) removeTodoWith(id int, policy subtaskPolicy) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&removeTodoCommand{id: id, policy: policy})
+}
+
+func (l *todoList) completeTodo(id int) error {
+	return l.completeTodoWith(id, false)
+}
+
+// completeTodoWith completes a todo, and its open subtasks too if
+// withSubtasks is set.
+func (l *todoList) completeTodoWith(id int, withSubtasks bool) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&completeTodoCommand{id: id, completed: true, withSubtasks: withSubtasks})
 }
 
-func (l *todoList) addTodos(todos ...*todo) {
-	l.todos = append(l.todos, todos...)
+func (l *todoList) uncompleteTodo(id int) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&completeTodoCommand{id: id, completed: false})
+}
+
+// getTodo returns a copy of the todo with the given ID.
+func (l *todoList) getTodo(id int) (*todo, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	if err := l.store.refresh(); err != nil {
+		return nil, err
+	}
+	t, err := l.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return l.view(t), nil
 }
 
-func (l *todoList) removeTodo(id int) {
-	for i := range l.todos {
-		if i == id {
-			l.todos = append(l.todos[:i], l.todos[i+1:]...)
+// editTodo applies the edit to the todo with the given ID.
+func (l *todoList) editTodo(id int, e todoEdit) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&editTodoCommand{id: id, edit: e})
+}
+
+func (l *todoList) reset() error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return l.execute(&resetListCommand{})
+}
+
+// execute runs the command, saves the list and records the command for
+// undo. The store's lock must be held.
+func (l *todoList) execute(c command) error {
+	err := l.store.update(func() error {
+		if err := l.check(); err != nil {
+			return err
 		}
+		return c.do(l)
+	})
+	if err != nil {
+		return err
 	}
+	l.history.push(c)
+	return nil
 }
 
-func (l *todoList) completeTodo(id int) {
-	for i, t := range l.todos {
-		if i == id {
-			t.setCompleted(true)
+// undo reverts the last command run in this session.
+func (l *todoList) undo() error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	h := &l.history
+	if len(h.done) == 0 {
+		return emptyHistoryError("undo")
+	}
+	c := h.done[len(h.done)-1]
+	err := l.store.update(func() error {
+		if err := l.check(); err != nil {
+			return err
+		}
+		c.undo(l)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, c)
+	return nil
+}
+
+// redo runs the last undone command again.
+func (l *todoList) redo() error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	h := &l.history
+	if len(h.undone) == 0 {
+		return emptyHistoryError("redo")
+	}
+	c := h.undone[len(h.undone)-1]
+	err := l.store.update(func() error {
+		if err := l.check(); err != nil {
+			return err
 		}
+		return c.do(l)
+	})
+	if err != nil {
+		return err
+	}
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, c)
+	return nil
+}
+
+// check fails if another process deleted or renamed the list. The
+// store's lock must be held.
+func (l *todoList) check() error {
+	if !slices.Contains(l.store.lists, l) {
+		return listNotFoundError(l.name)
 	}
+	return nil
 }
 
-func (l *todoList) reset() {
-	l.todos = []*todo{}
+// insert puts t at index i of the list, or at its end if the list is
+// shorter. The store's lock must be held.
+func (l *todoList) insert(i int, t *todo) {
+	i = min(i, len(l.todos))
+	l.todos = slices.Insert(l.todos, i, t)
+	l.store.record(change{kind: todoInserted, list: l.name, index: i, record: t.record()})
+}
+
+// remove takes t out of the list and returns where it was, or -1 if it
+// isn't in the list. The store's lock must be held.
+func (l *todoList) remove(t *todo) int {
+	i := slices.Index(l.todos, t)
+	if i >= 0 {
+		l.todos = slices.Delete(l.todos, i, i+1)
+		l.store.record(change{kind: todoDeleted, list: l.name, index: i, record: t.record()})
+	}
+	return i
+}
+
+// update sets t to v. The store's lock must be held.
+func (l *todoList) update(t *todo, v todo) {
+	*t = v
+	l.store.record(change{kind: todoUpdated, list: l.name, record: t.record()})
+}
+
+func (l *todoList) find(id int) (*todo, error) {
+	i, err := l.indexOf(id)
+	if err != nil {
+		return nil, err
+	}
+	return l.todos[i], nil
+}
+
+func (l *todoList) indexOf(id int) (int, error) {
+	for i, t := range l.todos {
+		if t.id == id {
+			return i, nil
+		}
+	}
+	return -1, notFoundError(id)
 }
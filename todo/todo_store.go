@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TodoStore loads and saves the lists of a store. Several processes can
+// use the same one, such as the menu, a command and the server: a store
+// locks it for every change and loads what the others saved first. The
+// store calls it with its own lock held, so implementations need no
+// locking within the process.
+type TodoStore interface {
+	// Load returns the stored lists, or nil if they didn't change since
+	// the last Load or Save. After a failed Save it returns them again.
+	Load() (*storageData, error)
+	// Lock waits until no other process is changing the lists and keeps
+	// them from doing so until Unlock.
+	Lock() error
+	// Unlock lets other processes change the lists again. Changes not
+	// saved by then are dropped.
+	Unlock()
+	// Save writes the changes made while the store was locked.
+	Save(c *changes) error
+	Close() error
+}
+
+// changes are what a store changed while its TodoStore was locked. A
+// TodoStore writes either all lists or just the changes in log.
+type changes struct {
+	// all returns all lists as they are now.
+	all     func() *storageData
+	nextID  int
+	current string
+	log     []change
+}
+
+type changeKind uint8
+
+const (
+	// todoInserted puts record at index of list, moving the todos from
+	// there on down by one.
+	todoInserted changeKind = iota
+	todoUpdated
+	// todoDeleted deletes record from index of list, moving the todos
+	// after it up by one.
+	todoDeleted
+	// listCreated appends list at index.
+	listCreated
+	// listRenamed renames list to name.
+	listRenamed
+	// listDeleted deletes list at index and its todos.
+	listDeleted
+	reminderSent
+)
+
+// change is one change to the lists. Indexes number the todos of a list,
+// or the lists, from 0 without gaps.
+type change struct {
+	kind     changeKind
+	list     string
+	index    int
+	name     string
+	record   todoRecord
+	reminder reminderRecord
+}
+
+// openTodoStore picks the TodoStore for path: memory only for an empty
+// path, SQLite for a .db, .sqlite or .sqlite3 file and JSON otherwise.
+func openTodoStore(path string) (TodoStore, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return openSQLTodoStore(path)
+	}
+	if path == "" {
+		return newMemTodoStore(), nil
+	}
+	return newJSONTodoStore(path), nil
+}
+
+// memTodoStore keeps the lists in memory only, they are gone once the
+// process exits. Nothing else can change them.
+type memTodoStore struct {
+	data   *storageData
+	loaded bool
+}
+
+func newMemTodoStore() *memTodoStore {
+	return &memTodoStore{
+		data: &storageData{},
+	}
+}
+
+func (m *memTodoStore) Load() (*storageData, error) {
+	if m.loaded {
+		return nil, nil
+	}
+	m.loaded = true
+	return m.data, nil
+}
+
+func (m *memTodoStore) Lock() error {
+	return nil
+}
+
+func (m *memTodoStore) Unlock() {}
+
+func (m *memTodoStore) Save(c *changes) error {
+	m.data = c.all()
+	return nil
+}
+
+func (m *memTodoStore) Close() error {
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newClosingTestStore(t *testing.T, path string) *store {
+	t.Helper()
+	s := newTestStore(t, path)
+	t.Cleanup(func() { s.close() })
+	return s
+}
+
+func TestOpenTodoStore(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "*main.memTodoStore"},
+		{filepath.Join(dir, "todos.json"), "*main.jsonTodoStore"},
+		{filepath.Join(dir, "todos.DB"), "*main.sqlTodoStore"},
+		{filepath.Join(dir, "todos.sqlite3"), "*main.sqlTodoStore"},
+	}
+	for _, tt := range tests {
+		ts, err := openTodoStore(tt.path)
+		if err != nil {
+			t.Fatalf("openTodoStore(%q): %v", tt.path, err)
+		}
+		if got := fmt.Sprintf("%T", ts); got != tt.want {
+			t.Errorf("openTodoStore(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+		ts.Close()
+	}
+}
+
+func TestSQLTodoStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	s := newClosingTestStore(t, path)
+	l := s.currentList()
+
+	td := newTodo("pay rent", "by transfer")
+	td.due = time.Date(2024, time.March, 1, 9, 30, 0, 0, time.Local)
+	td.recurrence, _ = parseRecurrence("monthly", td.due)
+	td.priority = priorityHigh
+	td.tags = []string{"home", "money"}
+	l.addTodos(td, newTodo("call bank", ""))
+	l.addTodos(newSubtask("find IBAN", 1))
+	l.completeTodo(2)
+	s.createList("work")
+	s.createList("old")
+	s.moveTodo(2, "work")
+	s.renameList("work", "office")
+	s.deleteList("old")
+	s.switchList("office")
+
+	reopened := newClosingTestStore(t, path)
+	if names := reopened.listNames(); !slices.Equal(names, []string{"default", "office"}) {
+		t.Errorf("expected lists to be saved, got %v", names)
+	}
+	if name := reopened.currentList().name; name != "office" {
+		t.Errorf("expected the current list to be saved, got %q", name)
+	}
+	for _, name := range []string{"default", "office"} {
+		want, _ := s.getList(name)
+		got, _ := reopened.getList(name)
+		if len(got.todos) != len(want.todos) {
+			t.Fatalf("list %s: expected %d todos, got %d", name, len(want.todos), len(got.todos))
+		}
+		for i := range want.todos {
+			if !equalTodo(got.todos[i], want.todos[i]) {
+				t.Errorf("list %s: expected %+v, got %+v", name, *want.todos[i], *got.todos[i])
+			}
+		}
+	}
+	if reopened.nextID != s.nextID {
+		t.Errorf("expected next ID %d, got %d", s.nextID, reopened.nextID)
+	}
+}
+
+func TestSQLTodoStore_Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	s := newClosingTestStore(t, path)
+	l := s.currentList()
+	l.addTodos(newTodo("a", ""), newTodo("b", ""), newTodo("c", ""))
+	l.completeTodo(2)
+	l.removeTodo(3)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var open, done int
+	err = db.QueryRow("SELECT count(*) FILTER (WHERE NOT completed), count(*) FILTER (WHERE completed) FROM todos").Scan(&open, &done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open != 1 || done != 1 {
+		t.Errorf("expected 1 open and 1 done todo, got %d and %d", open, done)
+	}
+
+	// only changed rows are written, a row changed by someone else is
+	// left alone
+	if _, err := db.Exec("UPDATE todos SET title = 'A' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	l.uncompleteTodo(2)
+	var title string
+	if err := db.QueryRow("SELECT title FROM todos WHERE id = 1").Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	if title != "A" {
+		t.Errorf("expected the unchanged todo not to be written, got %q", title)
+	}
+}
+
+func TestSQLTodoStore_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	ts, err := openSQLTodoStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	ts.db.QueryRow("PRAGMA user_version").Scan(&version)
+	if version != len(migrations) {
+		t.Errorf("expected schema version %d, got %d", len(migrations), version)
+	}
+	if err := ts.migrate(); err != nil {
+		t.Errorf("expected migrating again to do nothing, got %v", err)
+	}
+	ts.db.Exec("PRAGMA user_version = 99")
+	ts.Close()
+
+	if _, err := openSQLTodoStore(path); err == nil {
+		t.Error("expected a newer schema to be refused")
+	}
+}
+
+func TestSQLTodoStore_Shared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	a := newClosingTestStore(t, path)
+	b := newClosingTestStore(t, path)
+	la, lb := a.currentList(), b.currentList()
+	la.addTodos(newTodo("a1", ""), newTodo("a2", ""), newTodo("a3", ""))
+	lb.addTodos(newTodo("b1", ""))
+
+	// b deletes a todo a then edits, the edit doesn't bring it back
+	lb.removeTodo(2)
+	title := "A2"
+	if err := la.editTodo(2, todoEdit{title: &title}); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected the deleted todo not to be found, got %v", err)
+	}
+	// undoing the delete puts the todo back in its place
+	lb.undo()
+	la.completeTodo(3)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id, title, position, completed FROM todos ORDER BY position")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id, position int
+		var title string
+		var completed bool
+		rows.Scan(&id, &title, &position, &completed)
+		got = append(got, fmt.Sprintf("%d %s %d %v", id, title, position, completed))
+	}
+	want := []string{"1 a1 0 false", "2 a2 1 false", "3 a3 2 true", "4 b1 3 false"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected rows %q, got %q", want, got)
+	}
+	for _, s := range []*store{a, b} {
+		if got := titles(s.currentList()); got != "a1,a2,a3*,b1" {
+			t.Errorf("expected both stores to see all changes, got %q", got)
+		}
+	}
+}
+
+func TestSQLTodoStore_LoadChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	ts, err := openSQLTodoStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	if data, err := ts.Load(); data == nil || err != nil {
+		t.Fatalf("expected the first Load to read the lists, got %v, %v", data, err)
+	}
+	if data, err := ts.Load(); data != nil || err != nil {
+		t.Errorf("expected nothing to be read when nothing changed, got %v, %v", data, err)
+	}
+
+	other := newClosingTestStore(t, path)
+	other.currentList().addTodos(newTodo("a", ""))
+	data, err := ts.Load()
+	if err != nil || data == nil || len(data.Lists) != 1 || len(data.Lists[0].Todos) != 1 {
+		t.Errorf("expected the change of another connection to be read, got %+v, %v", data, err)
+	}
+}
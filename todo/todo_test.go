@@ -1,17 +1,58 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTodoList(t *testing.T, path string) *todoList {
+	t.Helper()
+	list, err := newTodoList(path)
+	if err != nil {
+		t.Fatalf("error creating todo list: %v", err)
+	}
+	return list
+}
+
+func equalTodo(a, b *todo) bool {
+	return a.id == b.id &&
+		a.title == b.title &&
+		a.description == b.description &&
+		a.completed == b.completed &&
+		a.due.Equal(b.due) &&
+		a.priority == b.priority &&
+		a.recurrence == b.recurrence &&
+		a.parentID == b.parentID &&
+		slices.Equal(a.tags, b.tags)
+}
 
 func TestPrintTodo(t *testing.T) {
 	td := newTodo("title", "description")
-	td.print()
+	var buf bytes.Buffer
+	td.print(&buf)
+
+	want := `
+ID:          0
+Title:       title
+Description: description
+Completed:   false
+`
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
 }
 
 func TestTodoList_AddTodo(t *testing.T) {
 	td1 := newTodo("title", "description")
 	td2 := newTodo("title", "description")
 	td3 := newTodo("title", "description")
-	list := newTodoList()
+	list := newTestTodoList(t, "")
 	list.addTodos(td1, td2, td3)
 	if len(list.todos) != 3 {
 		t.Error("expected 3 todos")
@@ -22,28 +63,339 @@ func TestTodoList_RemoveTodo(t *testing.T) {
 	td1 := newTodo("title", "description")
 	td2 := newTodo("title", "description")
 	td3 := newTodo("title", "description")
-	list := newTodoList()
+	list := newTestTodoList(t, "")
 	list.addTodos(td1, td2, td3)
 
-	list.removeTodo(0)
+	list.removeTodo(1)
 	if len(list.todos) != 2 {
 		t.Error("expected 2 todos")
 	}
+}
+
+func TestTodoList_StableIDs(t *testing.T) {
+	td1 := newTodo("first", "description")
+	td2 := newTodo("second", "description")
+	td3 := newTodo("third", "description")
+	list := newTestTodoList(t, "")
+	list.addTodos(td1, td2, td3)
+
+	for i, td := range []*todo{td1, td2, td3} {
+		if td.id != i+1 {
+			t.Errorf("expected id %d, got %d", i+1, td.id)
+		}
+	}
 
-	list.getTodos()
+	if err := list.removeTodo(td1.id); err != nil {
+		t.Fatalf("error removing todo: %v", err)
+	}
+	if err := list.removeTodo(td2.id); err != nil {
+		t.Fatalf("error removing todo: %v", err)
+	}
+	if len(list.todos) != 1 || list.todos[0] != td3 {
+		t.Fatalf("expected only %q to be left", td3.title)
+	}
+
+	td4 := newTodo("fourth", "description")
+	list.addTodos(td4)
+	if td4.id != 4 {
+		t.Errorf("expected id 4, got %d", td4.id)
+	}
+}
+
+func TestTodoList_NotFound(t *testing.T) {
+	list := newTestTodoList(t, "")
+	list.addTodos(newTodo("title", "description"))
+
+	if err := list.removeTodo(42); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error removing, got %v", err)
+	}
+	if err := list.completeTodo(42); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error completing, got %v", err)
+	}
+	if len(list.todos) != 1 || list.todos[0].completed {
+		t.Error("expected the list to be unchanged")
+	}
 }
 
 func TestTodoList_GetTodos(t *testing.T) {
 	td1 := newTodo("title", "description")
 	td2 := newTodo("title", "description")
 	td3 := newTodo("title", "description")
-	list := newTodoList()
+	list := newTestTodoList(t, "")
+
+	var buf bytes.Buffer
+	list.getTodos(&buf)
+	if buf.String() != "List of todos\n(empty)\n" {
+		t.Errorf("unexpected empty list output %q", buf.String())
+	}
+
 	list.addTodos(td1, td2, td3)
-	list.getTodos()
+	buf.Reset()
+	list.getTodos(&buf)
+	if n := bytes.Count(buf.Bytes(), []byte("Title:       title")); n != 3 {
+		t.Errorf("expected 3 todos printed, got %d", n)
+	}
+}
+
+func TestTodoList_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+
+	third := newTodo("third", "three")
+	third.due = time.Date(2024, time.March, 1, 9, 30, 0, 0, time.Local)
+	third.priority = priorityHigh
+	third.tags = []string{"work", "release"}
+	third.recurrence = recurrence{kind: recurEveryNDays, n: 3}
+
+	list := newTestTodoList(t, path)
+	if err := list.addTodos(newTodo("first", "one"), newTodo("second", "two"), third); err != nil {
+		t.Fatalf("error adding todos: %v", err)
+	}
+	if err := list.completeTodo(2); err != nil {
+		t.Fatalf("error completing todo: %v", err)
+	}
+	if err := list.removeTodo(1); err != nil {
+		t.Fatalf("error removing todo: %v", err)
+	}
+
+	loaded := newTestTodoList(t, path)
+	if len(loaded.todos) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(loaded.todos))
+	}
+	want := []*todo{
+		{id: 2, title: "second", description: "two", completed: true},
+		{id: 3, title: "third", description: "three", due: third.due, priority: priorityHigh, tags: []string{"work", "release"}, recurrence: third.recurrence},
+	}
+	for i, w := range want {
+		if !equalTodo(loaded.todos[i], w) {
+			t.Errorf("todo %d: expected %+v, got %+v", i, *w, *loaded.todos[i])
+		}
+	}
+
+	if err := loaded.reset(); err != nil {
+		t.Fatalf("error resetting todos: %v", err)
+	}
+	reloaded := newTestTodoList(t, path)
+	if len(reloaded.todos) != 0 {
+		t.Error("expected 0 todos after reset")
+	}
+	td := newTodo("fourth", "four")
+	reloaded.addTodos(td)
+	if td.id != 4 {
+		t.Errorf("expected IDs not to be reused after restart, got %d", td.id)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"todos.json", "todos.json.lock"}; !slices.Equal(names, want) {
+		t.Errorf("expected only the todo file and its lock to be left, got %v", names)
+	}
+}
+
+func TestTodoList_LoadMissingFile(t *testing.T) {
+	list := newTestTodoList(t, filepath.Join(t.TempDir(), "missing.json"))
+	if len(list.todos) != 0 {
+		t.Error("expected 0 todos")
+	}
+}
+
+func TestTodoList_LoadCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTodoList(path); err == nil {
+		t.Error("expected an error loading a corrupt file")
+	}
 }
 
 func TestMenu_Display(t *testing.T) {
-	l := newTodoList()
-	m := newMenu(l)
+	l := newTestTodoList(t, "")
+	var buf bytes.Buffer
+	m := newMenu(l.store, strings.NewReader(""), &buf)
 	m.display()
+	want := fmt.Sprintf(displayText, "default")
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// addPrompts are the prompts of the add option.
+const addPrompts = "Title: Description: Due (YYYY-MM-DD [HH:MM], optional): " +
+	"Repeat (daily/weekly/monthly [day]/every N days, optional): " +
+	"Priority (low/medium/high, optional): Tags (comma separated, optional): "
+
+// menuPrompt is the menu shown before every option on the given list.
+func menuPrompt(list string) string {
+	return fmt.Sprintf(displayText, list) + "Select an option: "
+}
+
+func TestMenu_Session(t *testing.T) {
+	input := strings.Join([]string{
+		"2", "Buy milk", "From the store", "2024-03-01", "", "high", "errands, home",
+		"2", "Walk dog", "", "", "", "", "",
+		"4", "1",
+		"3", "2",
+		"1",
+		"0",
+	}, "\n") + "\n"
+
+	l := newTestTodoList(t, "")
+	var out bytes.Buffer
+	m := newMenu(l.store, strings.NewReader(input), &out)
+	m.start()
+
+	prompt := menuPrompt("default")
+	want := prompt + addPrompts +
+		prompt + addPrompts +
+		prompt + "ID: " +
+		prompt + "ID: " +
+		prompt + `List of todos
+
+ID:          1
+Title:       Buy milk
+Description: From the store
+Completed:   true
+Due:         2024-03-01
+Priority:    high
+Tags:        errands, home
+` + prompt
+	if out.String() != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if len(l.todos) != 1 || !l.todos[0].completed {
+		t.Error("expected one completed todo")
+	}
+}
+
+func TestMenu_InvalidInput(t *testing.T) {
+	input := strings.Join([]string{
+		"99",
+		"3", "abc",
+		"4", "42",
+		"2", "", "no title", "", "", "", "",
+		"2", "title", "", "tomorrow",
+		"2", "title", "", "", "weekly", "", "",
+		"2", "title", "", "", "", "urgent",
+		"0",
+	}, "\n") + "\n"
+
+	l := newTestTodoList(t, "")
+	var out bytes.Buffer
+	m := newMenu(l.store, strings.NewReader(input), &out)
+	m.start()
+
+	prompt := menuPrompt("default")
+	want := prompt + "Sorry, \"99\" is not a valid option. Please try again.\n" +
+		prompt + "ID: Sorry, \"abc\" is not a valid ID. Please try again.\n" +
+		prompt + "ID: Sorry, no todo with ID 42. Please try again.\n" +
+		prompt + addPrompts +
+		"Sorry, title must not be empty. Please try again.\n" +
+		prompt + "Title: Description: Due (YYYY-MM-DD [HH:MM], optional): " +
+		"Sorry, \"tomorrow\" is not a valid due date, use YYYY-MM-DD or YYYY-MM-DD HH:MM. Please try again.\n" +
+		prompt + addPrompts +
+		"Sorry, a recurring todo needs a due date. Please try again.\n" +
+		prompt + "Title: Description: Due (YYYY-MM-DD [HH:MM], optional): " +
+		"Repeat (daily/weekly/monthly [day]/every N days, optional): Priority (low/medium/high, optional): " +
+		"Sorry, \"urgent\" is not a valid priority, use low, medium or high. Please try again.\n" +
+		prompt
+	if out.String() != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	if id, err := parseID("12"); err != nil || id != 12 {
+		t.Errorf("parseID(\"12\") = %d, %v", id, err)
+	}
+	for _, s := range []string{"", "abc", "0", "-1", "1.5"} {
+		if _, err := parseID(s); errorKind(err) != todoErrorKindInvalidID {
+			t.Errorf("parseID(%q): expected an invalid ID error, got %v", s, err)
+		}
+	}
+}
+
+func TestTodoList_EditTodo(t *testing.T) {
+	td := newTodo("title", "description")
+	td.priority = priorityLow
+	td.tags = []string{"home"}
+	list := newTestTodoList(t, "")
+	list.addTodos(td)
+
+	title := "new title"
+	due := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)
+	if err := list.editTodo(td.id, todoEdit{title: &title, due: &due}); err != nil {
+		t.Fatalf("error editing todo: %v", err)
+	}
+	want := &todo{id: td.id, title: "new title", description: "description", due: due, priority: priorityLow, tags: []string{"home"}}
+	if !equalTodo(td, want) {
+		t.Errorf("expected %+v, got %+v", *want, *td)
+	}
+
+	empty := ""
+	if err := list.editTodo(td.id, todoEdit{title: &empty}); errorKind(err) != todoErrorKindInvalidInput {
+		t.Errorf("expected an invalid input error, got %v", err)
+	}
+	if err := list.editTodo(42, todoEdit{title: &title}); errorKind(err) != todoErrorKindNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestMenu_EditAndUncomplete(t *testing.T) {
+	input := strings.Join([]string{
+		"7", "1", "Buy oat milk", "", "-", "", "low", "",
+		"4", "1",
+		"8", "1",
+		"1",
+		"0",
+	}, "\n") + "\n"
+
+	td := newTodo("Buy milk", "From the store")
+	td.due = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)
+	td.priority = priorityHigh
+	td.tags = []string{"errands"}
+	l := newTestTodoList(t, "")
+	l.addTodos(td)
+
+	var out bytes.Buffer
+	m := newMenu(l.store, strings.NewReader(input), &out)
+	m.start()
+
+	prompt := menuPrompt("default")
+	want := prompt + "ID: Press enter to keep a value, - to clear it.\n" +
+		"Title [Buy milk]: Description [From the store]: Due [2024-03-01]: Repeat []: Priority [high]: Tags [errands]: " +
+		prompt + "ID: " +
+		prompt + "ID: " +
+		prompt + `List of todos
+
+ID:          1
+Title:       Buy oat milk
+Description: From the store
+Completed:   false
+Priority:    low
+Tags:        errands
+` + prompt
+	if out.String() != want {
+		t.Errorf("unexpected transcript:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestMenu_EOF(t *testing.T) {
+	l := newTestTodoList(t, "")
+	var out bytes.Buffer
+	m := newMenu(l.store, strings.NewReader("2\nunfinished"), &out)
+	m.start()
+
+	want := menuPrompt("default") + "Title: Description: "
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+	if len(l.todos) != 0 {
+		t.Error("expected no todo to be added")
+	}
 }
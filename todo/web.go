@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.gohtml
+var templated embed.FS
+
+var todoTemplates = template.Must(template.ParseFS(templated, "templates/*.gohtml"))
+
+const (
+	csrfCookieName = "todo_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+type todoForm struct {
+	Title       string
+	Description string
+	Due         string
+	Priority    string
+	Tags        string
+}
+
+type todoView struct {
+	ID          int
+	Title       string
+	Description string
+	Due         string
+	Priority    string
+	Tags        string
+	Completed   bool
+	Overdue     bool
+	// HasSubtasks asks what to do with the subtasks on delete.
+	HasSubtasks bool
+}
+
+type pageData struct {
+	Title      string
+	CSRFToken  string
+	Error      string
+	Form       todoForm
+	Priorities []string
+	Todos      []todoView
+}
+
+// webRoutes registers the browser UI. Every form goes through a POST that
+// redirects back to the list, so reloading the page never submits twice.
+func (s *server) webRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", s.indexPage)
+	mux.HandleFunc("POST /add", s.csrfProtect(s.addForm))
+	mux.HandleFunc("POST /complete/{id}", s.csrfProtect(s.completeForm))
+	mux.HandleFunc("POST /delete/{id}", s.csrfProtect(s.deleteForm))
+}
+
+func (s *server) indexPage(w http.ResponseWriter, r *http.Request) {
+	s.renderPage(w, r, http.StatusOK, todoForm{}, "")
+}
+
+func (s *server) addForm(w http.ResponseWriter, r *http.Request) {
+	form := todoForm{
+		Title:       strings.TrimSpace(r.PostForm.Get("title")),
+		Description: r.PostForm.Get("description"),
+		Due:         r.PostForm.Get("due"),
+		Priority:    r.PostForm.Get("priority"),
+		Tags:        r.PostForm.Get("tags"),
+	}
+	due, err := parseDue(form.Due)
+	if err != nil {
+		s.renderPage(w, r, http.StatusBadRequest, form, err.Error())
+		return
+	}
+	p, err := parsePriority(form.Priority)
+	if err != nil {
+		s.renderPage(w, r, http.StatusBadRequest, form, err.Error())
+		return
+	}
+	t := newTodo(form.Title, form.Description)
+	t.due = due
+	t.priority = p
+	t.tags = parseTags(form.Tags)
+	l, err := s.todoList()
+	if err == nil {
+		err = l.addTodos(t)
+	}
+	if err != nil {
+		s.renderFormError(w, r, form, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *server) completeForm(w http.ResponseWriter, r *http.Request) {
+	s.idForm(w, r, (*todoList).completeTodo)
+}
+
+// deleteForm removes a todo, deleting or keeping its subtasks as the
+// subtasks field says.
+func (s *server) deleteForm(w http.ResponseWriter, r *http.Request) {
+	policy, err := parseSubtaskPolicy(r.PostFormValue("subtasks"))
+	if err != nil {
+		s.renderFormError(w, r, todoForm{}, err)
+		return
+	}
+	s.idForm(w, r, func(l *todoList, id int) error {
+		return l.removeTodoWith(id, policy)
+	})
+}
+
+func (s *server) idForm(w http.ResponseWriter, r *http.Request, f func(l *todoList, id int) error) {
+	id, err := parseID(r.PathValue("id"))
+	var l *todoList
+	if err == nil {
+		l, err = s.todoList()
+	}
+	if err == nil {
+		err = f(l, id)
+	}
+	if err != nil {
+		s.renderFormError(w, r, todoForm{}, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *server) renderFormError(w http.ResponseWriter, r *http.Request, form todoForm, err error) {
+	switch errorKind(err) {
+	case todoErrorKindNotFound:
+		s.renderPage(w, r, http.StatusNotFound, form, err.Error())
+	case todoErrorKindInvalidID, todoErrorKindInvalidInput:
+		s.renderPage(w, r, http.StatusBadRequest, form, err.Error())
+	case todoErrorKindHasSubtasks:
+		s.renderPage(w, r, http.StatusConflict, form, err.Error())
+	default:
+		log.Printf("error handling form: %v", err)
+		s.renderPage(w, r, http.StatusInternalServerError, form, "something went wrong, please try again")
+	}
+}
+
+func (s *server) renderPage(w http.ResponseWriter, r *http.Request, status int, form todoForm, message string) {
+	token, err := csrfToken(w, r)
+	if err != nil {
+		log.Printf("error creating CSRF token: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	var todos []*todo
+	if l, err := s.todoList(); errorKind(err) == todoErrorKindNotFound {
+		status, message = http.StatusNotFound, err.Error()
+	} else if err != nil {
+		log.Printf("error loading list: %v", err)
+		status, message = http.StatusInternalServerError, "something went wrong, please try again"
+	} else {
+		todos = l.listTodos(listOptions{}, now)
+	}
+	views := make([]todoView, 0, len(todos))
+	for _, t := range todos {
+		v := todoView{
+			ID:          t.id,
+			Title:       t.title,
+			Description: t.description,
+			Priority:    t.priority.String(),
+			Tags:        strings.Join(t.tags, ", "),
+			Completed:   t.completed,
+			Overdue:     t.overdue(now),
+			HasSubtasks: t.progress.total > 0,
+		}
+		if !t.due.IsZero() {
+			v.Due = formatDue(t.due)
+		}
+		views = append(views, v)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err = todoTemplates.ExecuteTemplate(w, "layout", pageData{
+		Title:      "Todo List",
+		CSRFToken:  token,
+		Error:      message,
+		Form:       form,
+		Priorities: []string{priorityLow.String(), priorityMedium.String(), priorityHigh.String()},
+		Todos:      views,
+	})
+	if err != nil {
+		log.Printf("error rendering page: %v", err)
+	}
+}
+
+// csrfToken returns the token of the CSRF cookie, setting a new cookie if
+// the request has none. Forms echo the token back in a hidden field.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookieName); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// csrfProtect rejects form posts whose hidden token doesn't match the CSRF
+// cookie. A cross-site page can make the browser send the cookie but
+// can't read it to fill in the form.
+func (s *server) csrfProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		c, err := r.Cookie(csrfCookieName)
+		token := r.PostForm.Get(csrfFieldName)
+		if err != nil || c.Value == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) != 1 {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
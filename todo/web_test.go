@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfFieldPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// browse loads the list page and returns the CSRF cookie and the token
+// embedded in its forms.
+func browse(t *testing.T, h http.Handler) (*http.Cookie, string, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly CSRF cookie, got %v", cookies)
+	}
+	m := csrfFieldPattern.FindStringSubmatch(rec.Body.String())
+	if m == nil {
+		t.Fatal("expected the page to contain a CSRF token")
+	}
+	return cookies[0], m[1], rec.Body.String()
+}
+
+func postForm(h http.Handler, target string, cookie *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWeb_PostRedirectGet(t *testing.T) {
+	list := newTestTodoList(t, "")
+	h := newServer(list).routes()
+
+	cookie, token, body := browse(t, h)
+	if !strings.Contains(body, "<title>Todo List</title>") || !strings.Contains(body, "(empty)") {
+		t.Errorf("unexpected page:\n%s", body)
+	}
+
+	rec := postForm(h, "/add", cookie, url.Values{
+		csrfFieldName: {token},
+		"title":       {"<script>alert(1)</script>"},
+		"priority":    {"high"},
+		"tags":        {"work"},
+	})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("expected a redirect to /, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	_, _, body = browse(t, h)
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("expected the title to be escaped:\n%s", body)
+	}
+	if !strings.Contains(body, `action="/complete/1"`) {
+		t.Errorf("expected a complete button:\n%s", body)
+	}
+
+	if rec := postForm(h, "/complete/1", cookie, url.Values{csrfFieldName: {token}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("complete: expected 303, got %d", rec.Code)
+	}
+	if !list.todos[0].completed {
+		t.Error("expected the todo to be completed")
+	}
+	if rec := postForm(h, "/delete/1", cookie, url.Values{csrfFieldName: {token}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("delete: expected 303, got %d", rec.Code)
+	}
+	if len(list.todos) != 0 {
+		t.Error("expected the todo to be deleted")
+	}
+}
+
+func TestWeb_CSRF(t *testing.T) {
+	list := newTestTodoList(t, "")
+	h := newServer(list).routes()
+	cookie, token, _ := browse(t, h)
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+	}{
+		{"no cookie", nil, token},
+		{"no token", cookie, ""},
+		{"wrong token", cookie, token + "x"},
+		{"other cookie", &http.Cookie{Name: csrfCookieName, Value: "forged"}, token},
+	}
+	for _, tt := range tests {
+		rec := postForm(h, "/add", tt.cookie, url.Values{csrfFieldName: {tt.token}, "title": {"title"}})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", tt.name, rec.Code)
+		}
+	}
+	if len(list.todos) != 0 {
+		t.Error("expected no todo to be added")
+	}
+}
+
+func TestWeb_InvalidForm(t *testing.T) {
+	list := newTestTodoList(t, "")
+	h := newServer(list).routes()
+	cookie, token, _ := browse(t, h)
+
+	rec := postForm(h, "/add", cookie, url.Values{csrfFieldName: {token}, "title": {"deploy"}, "due": {"someday"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "is not a valid due date") || !strings.Contains(body, `value="deploy"`) {
+		t.Errorf("expected the error and the typed values to be shown:\n%s", body)
+	}
+
+	if rec := postForm(h, "/delete/42", cookie, url.Values{csrfFieldName: {token}}); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestWeb_DeleteSubtasks(t *testing.T) {
+	list := newTestTodoList(t, "")
+	child := newTodo("boxes", "")
+	child.parentID = 1
+	list.addTodos(newTodo("pack", ""))
+	list.addTodos(child)
+	h := newServer(list).routes()
+
+	cookie, token, body := browse(t, h)
+	if strings.Count(body, `name="subtasks"`) != 1 {
+		t.Errorf("expected a subtasks choice on the parent only:\n%s", body)
+	}
+
+	if rec := postForm(h, "/delete/1", cookie, url.Values{csrfFieldName: {token}}); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 without a choice, got %d", rec.Code)
+	}
+	if rec := postForm(h, "/delete/1", cookie, url.Values{csrfFieldName: {token}, "subtasks": {"purge"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown choice, got %d", rec.Code)
+	}
+	if rec := postForm(h, "/delete/1", cookie, url.Values{csrfFieldName: {token}, "subtasks": {"keep"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", rec.Code)
+	}
+	if got := titles(list); got != "boxes" || list.todos[0].parentID != 0 {
+		t.Errorf("expected the subtask to be kept as a todo, got %q", got)
+	}
+}